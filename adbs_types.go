@@ -1,10 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	decoder "github.com/kc8/dump-1090-aggergator/decoder"
 )
 
 const (
@@ -32,6 +36,7 @@ type FormattedAdbsMsg struct {
 	AircraftId        string // unused
 	AircraftICAOAddr  string // in hex
 	FlightRecordNumer string // unused
+	ReceiverId        string // which receiver the message was read from
 
 	GeneratedTimestamp time.Time
 	LoggedTimestamp    time.Time
@@ -48,10 +53,20 @@ type FormattedAdbsMsg struct {
 	Emergency         Nullable[int]
 	TransponderIdent  Nullable[int]
 	IsOnGround        Nullable[int]
+	Status            string // STA only, one of the SBS_STATUS values
+
+	// only present on feeds that carry them (beast)
+	MLATTimestamp Nullable[uint64]
+	SignalLevel   Nullable[float32] // dBFS
+	// raw feeds send positions as CPR, resolved per aircraft in updateEntry
+	CPR Nullable[decoder.CPRFrame]
 }
 
 func getTimeStamp(dateStamp string, timeStamp string) (*time.Time, error) {
-	gDate, tStampErr := time.Parse("2006/01/02", dateStamp)
+	gDate, tStampErr := time.ParseInLocation("2006/01/02", dateStamp, receiverTimezone)
+	if tStampErr != nil {
+		return nil, tStampErr
+	}
 	gTime, tStampErr := time.Parse("15:04:05.000", timeStamp)
 	if tStampErr != nil {
 		return nil, tStampErr
@@ -90,8 +105,96 @@ func parseStringToIntWith0AsInvalid(txt string) (Nullable[int], error) {
 	return Nullable[int]{Value: int(altitude), Valid: true}, nil
 }
 
+// SBS (BaseStation) message types, see http://woodair.net/sbs/article/barebones42_socket_data.htm
+const (
+	SBS_MSG = "MSG" // transmission message, TransmissionType 1-8
+	SBS_SEL = "SEL" // selection change, carries the callsign
+	SBS_ID  = "ID"  // new id, carries the callsign
+	SBS_AIR = "AIR" // new aircraft
+	SBS_STA = "STA" // status change, carries one of the SBS_STATUS values
+	SBS_CLK = "CLK" // clock, not about any aircraft
+
+	SBS_FIELD_COUNT = 22 // MSG lines, the other types stop after the logged time or one field past it
+
+	SBS_STATUS_OK            = "OK"
+	SBS_STATUS_POSITION_LOST = "PL"
+	SBS_STATUS_SIGNAL_LOST   = "SL"
+	SBS_STATUS_REMOVED       = "RM"
+	SBS_STATUS_DELETED       = "AD"
+)
+
+var (
+	SBS_TYPE_FIELD_COUNTS = map[string]int{
+		SBS_MSG: SBS_FIELD_COUNT,
+		SBS_SEL: 11,
+		SBS_ID:  11,
+		SBS_AIR: 10,
+		SBS_STA: 11,
+		SBS_CLK: 10,
+	}
+	// fields each MSG transmission type carries, anything else in the line is ignored
+	SBS_MSG_FIELDS = map[string][]int{
+		"1": {10},                         // ES identification and category
+		"2": {11, 12, 13, 14, 15, 21},     // ES surface position
+		"3": {11, 14, 15, 18, 19, 20, 21}, // ES airborne position
+		"4": {12, 13, 16},                 // ES airborne velocity
+		"5": {11, 18, 20, 21},             // surveillance altitude
+		"6": {11, 17, 18, 19, 20, 21},     // surveillance id
+		"7": {11, 21},                     // air to air
+		"8": {21},                         // all call reply
+	}
+	SBS_STATUSES = []string{SBS_STATUS_OK, SBS_STATUS_POSITION_LOST, SBS_STATUS_SIGNAL_LOST, SBS_STATUS_REMOVED, SBS_STATUS_DELETED}
+)
+
+// isIcaoAddr accepts 6 hex digits, readsb marks addresses that are not ICAO with a leading ~
+func isIcaoAddr(txt string) bool {
+	txt = strings.TrimPrefix(txt, "~")
+	if len(txt) != 6 {
+		return false
+	}
+	_, err := strconv.ParseUint(txt, 16, 32)
+	return err == nil
+}
+
+// isAircraftLost is a STA message saying the receiver stopped tracking the aircraft
+func isAircraftLost(msg *FormattedAdbsMsg) bool {
+	return msg.MessageType == SBS_STA &&
+		(msg.Status == SBS_STATUS_SIGNAL_LOST || msg.Status == SBS_STATUS_REMOVED || msg.Status == SBS_STATUS_DELETED)
+}
+
+// ParseCSVFormat parses one SBS line, CLK lines are checked and then skipped with errFrameSkipped
 func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
-	stringSplit := strings.Split(string(msg), ",")
+	stringSplit := strings.Split(strings.TrimRight(string(msg), "\r\n"), ",")
+	fieldCount, ok := SBS_TYPE_FIELD_COUNTS[stringSplit[0]]
+	if ok == false {
+		return nil, ADBSParseError{
+			FullMessage:     string(msg),
+			UnderlyingError: errors.New(fmt.Sprintf("Unknown message type %q", stringSplit[0])),
+			IndexPosition:   0,
+		}
+	}
+	if len(stringSplit) < fieldCount {
+		return nil, ADBSParseError{
+			FullMessage:     string(msg),
+			UnderlyingError: errors.New(fmt.Sprintf("Expected %d fields for %s got %d", fieldCount, stringSplit[0], len(stringSplit))),
+			IndexPosition:   len(stringSplit),
+		}
+	}
+	carried, ok := SBS_MSG_FIELDS[stringSplit[1]]
+	if stringSplit[0] == SBS_MSG && ok == false {
+		return nil, ADBSParseError{
+			FullMessage:     string(msg),
+			UnderlyingError: errors.New(fmt.Sprintf("Unknown transmission type %q expected 1-8", stringSplit[1])),
+			IndexPosition:   1,
+		}
+	}
+	if stringSplit[0] != SBS_CLK && isIcaoAddr(stringSplit[4]) == false {
+		return nil, ADBSParseError{
+			FullMessage:     string(msg),
+			UnderlyingError: errors.New(fmt.Sprintf("Invalid ICAO address %q", stringSplit[4])),
+			IndexPosition:   4,
+		}
+	}
 
 	generatedTimestamp, tStampErr := getTimeStamp(stringSplit[6], stringSplit[7])
 	if tStampErr != nil {
@@ -110,7 +213,45 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 		}
 	}
 
-	altitude, err := parseStringToFloatWith0AsInvalid(stringSplit[11])
+	result := FormattedAdbsMsg{
+		MessageType:        stringSplit[0],
+		TransmissionType:   stringSplit[1],
+		SessionID:          stringSplit[2],
+		AircraftId:         stringSplit[3],
+		AircraftICAOAddr:   stringSplit[4],
+		FlightRecordNumer:  stringSplit[5],
+		GeneratedTimestamp: *generatedTimestamp,
+		LoggedTimestamp:    *loggedTimestamp,
+	}
+	switch stringSplit[0] {
+	case SBS_CLK:
+		return nil, errFrameSkipped
+	case SBS_AIR:
+		return &result, nil
+	case SBS_SEL, SBS_ID:
+		result.CallsignFlightNum = strings.TrimSpace(stringSplit[10])
+		return &result, nil
+	case SBS_STA:
+		result.Status = strings.TrimSpace(stringSplit[10])
+		if slices.Contains(SBS_STATUSES, result.Status) == false {
+			return nil, ADBSParseError{
+				FullMessage:     string(msg),
+				UnderlyingError: errors.New(fmt.Sprintf("Unknown status %q", result.Status)),
+				IndexPosition:   10,
+			}
+		}
+		return &result, nil
+	}
+
+	// MSG, only the fields its transmission type carries are read
+	field := func(i int) string {
+		if slices.Contains(carried, i) {
+			return strings.TrimSpace(stringSplit[i])
+		}
+		return ""
+	}
+
+	altitude, err := parseStringToFloatWith0AsInvalid(field(11))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -118,7 +259,7 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 			IndexPosition: 11,
 		}
 	}
-	groundSpeed, err := parseStringToFloatWith0AsInvalid(stringSplit[12])
+	groundSpeed, err := parseStringToFloatWith0AsInvalid(field(12))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -126,7 +267,7 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 			IndexPosition: 12,
 		}
 	}
-	headingTrack, err := parseStringToIntWith0AsInvalid(stringSplit[13])
+	headingTrack, err := parseStringToIntWith0AsInvalid(field(13))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -134,7 +275,7 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 			IndexPosition: 13,
 		}
 	}
-	lat, err := parseStringToFloatWith0AsInvalid(stringSplit[14])
+	lat, err := parseStringToFloatWith0AsInvalid(field(14))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -142,7 +283,7 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 			IndexPosition: 14,
 		}
 	}
-	long, err := parseStringToFloatWith0AsInvalid(stringSplit[15])
+	long, err := parseStringToFloatWith0AsInvalid(field(15))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -150,7 +291,7 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 			IndexPosition: 15,
 		}
 	}
-	vertRate, err := parseStringToFloatWith0AsInvalid(stringSplit[16])
+	vertRate, err := parseStringToFloatWith0AsInvalid(field(16))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -158,7 +299,7 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 			IndexPosition: 16,
 		}
 	}
-	squawk, err := parseStringToIntWith0AsInvalid(stringSplit[17])
+	squawk, err := parseStringToIntWith0AsInvalid(field(17))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -166,7 +307,7 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 			IndexPosition: 17,
 		}
 	}
-	squawkChange, err := parseStringToIntWith0AsInvalid(stringSplit[18])
+	squawkChange, err := parseStringToIntWith0AsInvalid(field(18))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -174,7 +315,7 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 			IndexPosition: 18,
 		}
 	}
-	emergency, err := parseStringToIntWith0AsInvalid(stringSplit[19])
+	emergency, err := parseStringToIntWith0AsInvalid(field(19))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -182,7 +323,7 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 			IndexPosition: 19,
 		}
 	}
-	transPonderIdent, err := parseStringToIntWith0AsInvalid(stringSplit[20])
+	transPonderIdent, err := parseStringToIntWith0AsInvalid(field(20))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -190,8 +331,7 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 			IndexPosition: 20,
 		}
 	}
-	// the slice at the end is because the buffer is filled with junk after the 21s index
-	isOnGround, err := parseStringToIntWith0AsInvalid(stringSplit[21][:0])
+	isOnGround, err := parseStringToIntWith0AsInvalid(field(21))
 	if err != nil {
 		return nil, ADBSParseError{
 			FullMessage:   string(msg),
@@ -200,29 +340,17 @@ func ParseCSVFormat(msg []byte) (*FormattedAdbsMsg, error) {
 		}
 	}
 
-	result := FormattedAdbsMsg{
-		MessageType:       stringSplit[0],
-		TransmissionType:  stringSplit[1],
-		SessionID:         stringSplit[2],
-		AircraftId:        stringSplit[3],
-		AircraftICAOAddr:  stringSplit[4],
-		FlightRecordNumer: stringSplit[5],
-
-		GeneratedTimestamp: *generatedTimestamp,
-
-		LoggedTimestamp:   *loggedTimestamp,
-		CallsignFlightNum: stringSplit[10],
-		Altitude:          altitude,
-		GroundSpeed:       groundSpeed,
-		HeadingTrack:      headingTrack,
-		Lat:               lat,
-		Long:              long,
-		VerticalRate:      vertRate,
-		SquawkCode:        squawk,
-		SquawkChange:      squawkChange,
-		Emergency:         emergency,
-		TransponderIdent:  transPonderIdent,
-		IsOnGround:        isOnGround,
-	}
+	result.CallsignFlightNum = field(10)
+	result.Altitude = altitude
+	result.GroundSpeed = groundSpeed
+	result.HeadingTrack = headingTrack
+	result.Lat = lat
+	result.Long = long
+	result.VerticalRate = vertRate
+	result.SquawkCode = squawk
+	result.SquawkChange = squawkChange
+	result.Emergency = emergency
+	result.TransponderIdent = transPonderIdent
+	result.IsOnGround = isOnGround
 	return &result, nil
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+func TestParseCSVFormatMessageTypes(t *testing.T) {
+	id, err := ParseCSVFormat([]byte("ID,,496,7162,405637,27928,2010/02/19,18:06:07.115,2010/02/19,18:06:07.115,EZY691A "))
+	if err != nil {
+		t.Fatalf("Expected ID message got err %s", err.Error())
+	}
+	if id.AircraftICAOAddr != "405637" || id.CallsignFlightNum != "EZY691A" {
+		t.Fatalf("Expected 405637 EZY691A got %s %q", id.AircraftICAOAddr, id.CallsignFlightNum)
+	}
+	air, err := ParseCSVFormat([]byte("AIR,,496,6,405637,27928,2010/02/19,18:06:07.115,2010/02/19,18:06:07.115"))
+	if err != nil || air.MessageType != SBS_AIR {
+		t.Fatalf("Expected AIR message got %v %v", air, err)
+	}
+	sta, err := ParseCSVFormat([]byte("STA,,5,179,400AE7,10103,2008/11/28,14:58:51.153,2008/11/28,14:58:51.153,RM"))
+	if err != nil {
+		t.Fatalf("Expected STA message got err %s", err.Error())
+	}
+	if isAircraftLost(sta) == false {
+		t.Fatalf("Expected RM to mean the aircraft was lost")
+	}
+	if _, err := ParseCSVFormat([]byte("CLK,,496,-1,,-1,2010/02/19,09:49:41.500,2010/02/19,09:49:41.500")); errors.Is(err, errFrameSkipped) == false {
+		t.Fatalf("Expected CLK to be skipped got %v", err)
+	}
+}
+
+func TestParseCSVFormatOnlyReadsCarriedFields(t *testing.T) {
+	// MSG 4 is velocity only, the altitude and on ground flag do not belong to it
+	msg, err := ParseCSVFormat([]byte("MSG,4,1,1,A1B2C3,1,2024/10/08,12:00:00.000,2024/10/08,12:00:00.000,,35000,450,90,,,-640,,,,,0"))
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if msg.Altitude.Valid || msg.IsOnGround.Valid {
+		t.Fatalf("Expected no altitude or on ground on MSG 4 got %v %v", msg.Altitude, msg.IsOnGround)
+	}
+	if msg.GroundSpeed.Value != 450 || msg.HeadingTrack.Value != 90 || msg.VerticalRate.Value != -640 {
+		t.Fatalf("Expected velocity 450 90 -640 got %v %v %v", msg.GroundSpeed, msg.HeadingTrack, msg.VerticalRate)
+	}
+}
+
+func TestParseCSVFormatOnGround(t *testing.T) {
+	msg, err := ParseCSVFormat([]byte("MSG,2,1,1,A1B2C3,1,2024/10/08,12:00:00.000,2024/10/08,12:00:00.000,,0,12,270,40.50000,-73.50000,,,0,0,0,-1\r"))
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if msg.IsOnGround.Valid == false || msg.IsOnGround.Value != -1 {
+		t.Fatalf("Expected on ground -1 got %v", msg.IsOnGround)
+	}
+}
+
+func TestParseCSVFormatRejectsMalformedLines(t *testing.T) {
+	cases := map[string]int{
+		"ID,,496,7162,405637,27928,2010/02/19,18:06:07.115":                              8,
+		"MSG,9,1,1,A1B2C3,1,2024/10/08,12:00:00.000,2024/10/08,12:00:00.000,,,,,,,,,,,,": 1,
+		"MSG,3,1,1,XYZ,1,2024/10/08,12:00:00.000,2024/10/08,12:00:00.000,,,,,,,,,,,,":    4,
+		"STA,,5,179,400AE7,10103,2008/11/28,14:58:51.153,2008/11/28,14:58:51.153,ZZ":     10,
+		"HELLO": 0,
+		"MSG,3,1,1,A1B2C3,1,2024/10/08,12:00:00.000,2024/10/08,12:00:00.000,,high,,,,,,,,,,": 11,
+	}
+	for line, index := range cases {
+		_, err := ParseCSVFormat([]byte(line))
+		var parseErr ADBSParseError
+		if errors.As(err, &parseErr) == false {
+			t.Fatalf("Expected %s to fail to parse got %v", line, err)
+		}
+		if parseErr.IndexPosition != index {
+			t.Fatalf("Expected %s to fail at %d got %d", line, index, parseErr.IndexPosition)
+		}
+	}
+}
+
+func TestAggregateMarksLostAircraft(t *testing.T) {
+	msg, _ := ParseCSVFormat([]byte(TEST_SBS_LINE))
+	lost, _ := ParseCSVFormat([]byte("STA,,1,1,A1B2C3,1,2024/10/08,12:00:05.000,2024/10/08,12:00:05.000,SL"))
+
+	if items := aggregate(storage.MapItem[CollectedData]{}, false, lost, 5000); len(items) != 0 {
+		t.Fatalf("Expected STA for an unknown aircraft to be dropped")
+	}
+	item := aggregate(storage.MapItem[CollectedData]{}, false, msg, 1000)[0]
+	item = aggregate(item, true, msg, 2000)[0]
+	item = aggregate(item, true, lost, 5000)[0]
+	if item.Data.Lost == false || item.Data.LastSeen != 2000 {
+		t.Fatalf("Expected lost aircraft last seen at 2000 got %v %d", item.Data.Lost, item.Data.LastSeen)
+	}
+	if isReadyToInsert(item, 2001) == false {
+		t.Fatalf("Expected a lost aircraft to be ready to insert")
+	}
+	item = aggregate(item, true, msg, 6000)[0]
+	if item.Data.Lost {
+		t.Fatalf("Expected a message after STA to mean the aircraft is back")
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// aircraft.json served by the SkyAware / tar1090 web ui of dump1090-fa and readsb,
+// older dump1090 forks use altitude/speed/vert_rate instead of alt_baro/gs/baro_rate
+type jsonAircraft struct {
+	Hex       string   `json:"hex"`
+	Flight    string   `json:"flight"`
+	AltBaro   any      `json:"alt_baro"` // feet or "ground"
+	Altitude  any      `json:"altitude"`
+	Gs        *float64 `json:"gs"`
+	Speed     *float64 `json:"speed"`
+	Track     *float64 `json:"track"`
+	BaroRate  *float64 `json:"baro_rate"`
+	GeomRate  *float64 `json:"geom_rate"`
+	VertRate  *float64 `json:"vert_rate"`
+	Squawk    string   `json:"squawk"`
+	Emergency string   `json:"emergency"`
+	Lat       *float64 `json:"lat"`
+	Lon       *float64 `json:"lon"`
+	Seen      float64  `json:"seen"`     // seconds since any message
+	SeenPos   *float64 `json:"seen_pos"` // seconds since the position was updated
+	Messages  uint64   `json:"messages"`
+	Rssi      *float64 `json:"rssi"`
+}
+
+type jsonAircraftFile struct {
+	Now      float64        `json:"now"`
+	Aircraft []jsonAircraft `json:"aircraft"`
+}
+
+// aircraft stay in aircraft.json for minutes after they are last heard, only what changed
+// since the last poll is passed on so LastSeen and MsgCount stay honest
+type jsonPollState struct {
+	messages     uint64
+	positionTime int64
+}
+
+func jsonFloat(values ...*float64) Nullable[float32] {
+	for _, v := range values {
+		if v != nil {
+			return Nullable[float32]{Value: float32(*v), Valid: true}
+		}
+	}
+	return Nullable[float32]{Valid: false}
+}
+
+// jsonAltitude returns the altitude and if the aircraft is on the ground
+func jsonAltitude(values ...any) (Nullable[float32], Nullable[int]) {
+	for _, v := range values {
+		switch alt := v.(type) {
+		case float64:
+			return Nullable[float32]{Value: float32(alt), Valid: true}, Nullable[int]{Value: 0, Valid: true}
+		case string:
+			if alt == "ground" {
+				return Nullable[float32]{Valid: false}, Nullable[int]{Value: -1, Valid: true}
+			}
+		}
+	}
+	return Nullable[float32]{Valid: false}, Nullable[int]{Valid: false}
+}
+
+func epochSecondsToTime(seconds float64) time.Time {
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
+
+func ParseAircraftJSON(body []byte, state map[string]jsonPollState) ([]*FormattedAdbsMsg, error) {
+	var file jsonAircraftFile
+	if err := json.Unmarshal(body, &file); err != nil {
+		return nil, err
+	}
+	results := make([]*FormattedAdbsMsg, 0, len(file.Aircraft))
+	stillListed := make(map[string]bool, len(file.Aircraft))
+	for _, aircraft := range file.Aircraft {
+		// ~ is a non ICAO address from TIS-B or MLAT
+		if aircraft.Hex == "" || strings.HasPrefix(aircraft.Hex, "~") {
+			continue
+		}
+		icao := strings.ToUpper(aircraft.Hex)
+		stillListed[icao] = true
+		previous, seenBefore := state[icao]
+		if seenBefore && previous.messages == aircraft.Messages {
+			continue
+		}
+		current := jsonPollState{messages: aircraft.Messages, positionTime: previous.positionTime}
+
+		generated := epochSecondsToTime(file.Now - aircraft.Seen)
+		altitude, onGround := jsonAltitude(aircraft.AltBaro, aircraft.Altitude)
+		squawk := Nullable[int]{Valid: false}
+		if aircraft.Squawk != "" {
+			// one bad field should not cost the rest of the poll
+			code, err := strconv.Atoi(aircraft.Squawk)
+			if err != nil {
+				Log(fmt.Sprintf("Ignoring invalid squawk %q for %s", aircraft.Squawk, icao), WARN)
+			} else {
+				squawk = Nullable[int]{Value: code, Valid: true}
+			}
+		}
+		emergency := Nullable[int]{Valid: false}
+		if aircraft.Emergency != "" {
+			emergency = Nullable[int]{Value: 0, Valid: true}
+			if aircraft.Emergency != "none" {
+				emergency.Value = -1
+			}
+		}
+		heading := Nullable[int]{Valid: false}
+		if aircraft.Track != nil {
+			heading = Nullable[int]{Value: int(*aircraft.Track), Valid: true}
+		}
+
+		lat := Nullable[float32]{Valid: false}
+		long := Nullable[float32]{Valid: false}
+		if aircraft.Lat != nil && aircraft.Lon != nil && aircraft.SeenPos != nil {
+			positionTime := epochSecondsToTime(file.Now - *aircraft.SeenPos).UnixMilli()
+			if positionTime != previous.positionTime {
+				lat = Nullable[float32]{Value: float32(*aircraft.Lat), Valid: true}
+				long = Nullable[float32]{Value: float32(*aircraft.Lon), Valid: true}
+				current.positionTime = positionTime
+			}
+		}
+		state[icao] = current
+
+		results = append(results, &FormattedAdbsMsg{
+			MessageType:        "JSON",
+			AircraftICAOAddr:   icao,
+			GeneratedTimestamp: generated,
+			LoggedTimestamp:    epochSecondsToTime(file.Now),
+			CallsignFlightNum:  strings.TrimSpace(aircraft.Flight),
+			Altitude:           altitude,
+			GroundSpeed:        jsonFloat(aircraft.Gs, aircraft.Speed),
+			HeadingTrack:       heading,
+			Lat:                lat,
+			Long:               long,
+			VerticalRate:       jsonFloat(aircraft.BaroRate, aircraft.VertRate, aircraft.GeomRate),
+			SquawkCode:         squawk,
+			Emergency:          emergency,
+			IsOnGround:         onGround,
+			SignalLevel:        jsonFloat(aircraft.Rssi),
+		})
+	}
+	for icao := range state {
+		if stillListed[icao] == false {
+			delete(state, icao)
+		}
+	}
+	return results, nil
+}
+
+func fetchAircraftJSON(ctx context.Context, client *http.Client, uri string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, errors.New(fmt.Sprintf("Unexpected result from server got response code: %d", res.StatusCode))
+	}
+	return io.ReadAll(res.Body)
+}
+
+func pollAircraftJSON(
+	ctx context.Context,
+	rcv receiver,
+	uri string,
+	interval time.Duration,
+	done chan bool,
+	itemQueue *modifyStoQueue) {
+
+	client := &http.Client{Timeout: interval + 5*time.Second}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	state := make(map[string]jsonPollState)
+	Log(fmt.Sprintf("Polling %s for receiver %s every %s", uri, rcv.Id, interval), INFO)
+
+	for {
+		select {
+		case <-done:
+			Log(fmt.Sprintf("Exiting Application"), INFO)
+			return
+		case <-ticker.C:
+			body, err := fetchAircraftJSON(ctx, client, uri)
+			if err != nil {
+				Log(fmt.Sprintf("Failed to fetch %s due to %s", uri, err.Error()), WARN)
+				continue
+			}
+			recordFeed(time.Now(), rcv, compactJSONRecord(body))
+			results, err := ParseAircraftJSON(body, state)
+			if err != nil {
+				Log(fmt.Sprintf("Failed to parse %s due to %s", uri, err.Error()), ERROR)
+				continue
+			}
+			for _, result := range results {
+				result.ReceiverId = rcv.Id
+				itemQueue.updateOrAdd(result)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+)
+
+const TEST_AIRCRAFT_JSON = `{ "now" : 1700000000.5, "messages" : 1000, "aircraft" : [
+  {"hex":"a1b2c3","flight":"UAL123  ","alt_baro":35000,"gs":450.5,"track":270.2,"baro_rate":-64,"squawk":"1200","emergency":"none","lat":40.5,"lon":-73.5,"seen_pos":1.5,"messages":10,"seen":0.5,"rssi":-12.3},
+  {"hex":"abcdef","alt_baro":"ground","gs":12,"messages":3,"seen":2},
+  {"hex":"~2f0001","alt_baro":1200,"messages":3,"seen":2}
+]}`
+
+func TestParseAircraftJSON(t *testing.T) {
+	state := make(map[string]jsonPollState)
+	results, err := ParseAircraftJSON([]byte(TEST_AIRCRAFT_JSON), state)
+	if err != nil {
+		t.Fatalf("Expected aircraft got err %s", err.Error())
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 aircraft without the non icao address got %d", len(results))
+	}
+	first := results[0]
+	if first.AircraftICAOAddr != "A1B2C3" || first.CallsignFlightNum != "UAL123" {
+		t.Fatalf("Expected A1B2C3 UAL123 got %s %s", first.AircraftICAOAddr, first.CallsignFlightNum)
+	}
+	if first.Altitude.Value != 35000 || first.VerticalRate.Value != -64 || first.SquawkCode.Value != 1200 {
+		t.Fatalf("Expected 35000ft -64fpm squawk 1200 got %v %v %v", first.Altitude, first.VerticalRate, first.SquawkCode)
+	}
+	if first.Lat.Valid == false || first.Long.Valid == false {
+		t.Fatalf("Expected a position")
+	}
+	if first.GeneratedTimestamp.UnixMilli() != 1700000000000 {
+		t.Fatalf("Expected generated timestamp to be now - seen got %d", first.GeneratedTimestamp.UnixMilli())
+	}
+	ground := results[1]
+	if ground.IsOnGround.Valid == false || ground.IsOnGround.Value != -1 || ground.Altitude.Valid {
+		t.Fatalf("Expected the second aircraft to be on the ground got %v %v", ground.IsOnGround, ground.Altitude)
+	}
+
+	again, err := ParseAircraftJSON([]byte(TEST_AIRCRAFT_JSON), state)
+	if err != nil {
+		t.Fatalf("Expected aircraft got err %s", err.Error())
+	}
+	if len(again) != 0 {
+		t.Fatalf("Expected nothing new on an unchanged poll got %d", len(again))
+	}
+}
+
+func TestParseAircraftJSONBadSquawk(t *testing.T) {
+	body := `{ "now" : 1700000000, "aircraft" : [
+  {"hex":"a1b2c3","squawk":"12x0","alt_baro":35000,"messages":10,"seen":0.5},
+  {"hex":"abcdef","squawk":"7000","messages":3,"seen":2}
+]}`
+	results, err := ParseAircraftJSON([]byte(body), make(map[string]jsonPollState))
+	if err != nil || len(results) != 2 {
+		t.Fatalf("Expected both aircraft despite the bad squawk got %d %v", len(results), err)
+	}
+	if results[0].SquawkCode.Valid || results[0].Altitude.Value != 35000 || results[1].SquawkCode.Value != 7000 {
+		t.Fatalf("Expected no squawk for A1B2C3 and 7000 for ABCDEF got %v %v", results[0].SquawkCode, results[1].SquawkCode)
+	}
+}
@@ -0,0 +1,222 @@
+package airports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	geo "github.com/kc8/dump-1090-aggergator/geo"
+)
+
+// Reads the public OurAirports dataset, see https://ourairports.com/data/
+// airports.csv and optionally runways.csv
+
+const (
+	// 1 degree cells, a lookup searches the cell of the point and its neighbours
+	GRID_DEGREES = 1.0
+	// the furthest Nearest will look, a cell is about 111km high so this stays within the neighbours
+	MAX_SEARCH_KM = 100.0
+)
+
+type Runway struct {
+	Ident   string  // the end, Example: 09L
+	Heading float64 // degrees true
+}
+
+type Airport struct {
+	Ident       string // ICAO or the OurAirports ident when there is none, Example: EHAM
+	Type        string // large_airport, small_airport, heliport ...
+	Name        string
+	Lat         float64
+	Lon         float64
+	ElevationFt float64
+	IataCode    string
+	Runways     []Runway
+}
+
+type gridCell struct {
+	lat int
+	lon int
+}
+
+// Index finds the airport nearest a position
+type Index struct {
+	airports map[string]*Airport
+	grid     map[gridCell][]*Airport
+}
+
+func cellOf(lat float64, lon float64) gridCell {
+	return gridCell{lat: int(math.Floor(lat / GRID_DEGREES)), lon: int(math.Floor(lon / GRID_DEGREES))}
+}
+
+// readCSV calls visit for every row with the header names as keys
+func readCSV(r io.Reader, visit func(row map[string]string) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[name] = record[i]
+			}
+		}
+		if err := visit(row); err != nil {
+			return err
+		}
+	}
+}
+
+// LoadAirports reads airports.csv, closed airports are left out
+func LoadAirports(r io.Reader) (*Index, error) {
+	index := &Index{
+		airports: make(map[string]*Airport),
+		grid:     make(map[gridCell][]*Airport),
+	}
+	err := readCSV(r, func(row map[string]string) error {
+		if row["type"] == "closed" {
+			return nil
+		}
+		lat, latErr := strconv.ParseFloat(row["latitude_deg"], 64)
+		lon, lonErr := strconv.ParseFloat(row["longitude_deg"], 64)
+		if latErr != nil || lonErr != nil {
+			return errors.New(fmt.Sprintf("Airport %s has an invalid position %s,%s", row["ident"], row["latitude_deg"], row["longitude_deg"]))
+		}
+		elevation, _ := strconv.ParseFloat(row["elevation_ft"], 64)
+		airport := &Airport{
+			Ident:       row["ident"],
+			Type:        row["type"],
+			Name:        row["name"],
+			Lat:         lat,
+			Lon:         lon,
+			ElevationFt: elevation,
+			IataCode:    row["iata_code"],
+		}
+		index.airports[airport.Ident] = airport
+		cell := cellOf(lat, lon)
+		index.grid[cell] = append(index.grid[cell], airport)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// runwayHeading uses the heading in the dataset and falls back to the runway number, 27 is 270 degrees
+func runwayHeading(ident string, heading string) (float64, bool) {
+	if value, err := strconv.ParseFloat(heading, 64); err == nil {
+		return value, true
+	}
+	digits := strings.TrimRight(ident, "LRCW")
+	number, err := strconv.Atoi(digits)
+	if err != nil || number < 1 || number > 36 {
+		return 0, false
+	}
+	return float64(number * 10), true
+}
+
+// LoadRunways adds runways.csv to airports already in the index
+func (i *Index) LoadRunways(r io.Reader) error {
+	return readCSV(r, func(row map[string]string) error {
+		airport, ok := i.airports[row["airport_ident"]]
+		if ok == false || row["closed"] == "1" {
+			return nil
+		}
+		for _, end := range []string{"le", "he"} {
+			ident := row[end+"_ident"]
+			if heading, ok := runwayHeading(ident, row[end+"_heading_degT"]); ok {
+				airport.Runways = append(airport.Runways, Runway{Ident: ident, Heading: heading})
+			}
+		}
+		return nil
+	})
+}
+
+// LoadFiles reads the OurAirports airports.csv and runways.csv, runwaysPath can be empty
+func LoadFiles(airportsPath string, runwaysPath string) (*Index, error) {
+	f, err := os.Open(airportsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	index, err := LoadAirports(f)
+	if err != nil {
+		return nil, err
+	}
+	if runwaysPath == "" {
+		return index, nil
+	}
+	r, err := os.Open(runwaysPath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return index, index.LoadRunways(r)
+}
+
+func (i *Index) Len() int {
+	return len(i.airports)
+}
+
+// Nearest returns the closest airport within maxKm, maxKm is capped at MAX_SEARCH_KM
+func (i *Index) Nearest(lat float64, lon float64, maxKm float64) (*Airport, float64, bool) {
+	var (
+		best     *Airport
+		bestDist = math.Min(maxKm, MAX_SEARCH_KM)
+	)
+	center := cellOf(lat, lon)
+	// cells get narrower towards the poles so look further east and west there
+	lonCells := 1
+	if cos := math.Cos(lat * math.Pi / 180); cos > 0.01 {
+		lonCells = int(math.Ceil(MAX_SEARCH_KM / (111 * GRID_DEGREES * cos)))
+	} else {
+		lonCells = int(360 / GRID_DEGREES)
+	}
+	for dLat := -1; dLat <= 1; dLat++ {
+		for dLon := -lonCells; dLon <= lonCells; dLon++ {
+			cell := gridCell{lat: center.lat + dLat, lon: center.lon + dLon}
+			// wrap around the antimeridian
+			cell.lon = ((cell.lon+180)%360+360)%360 - 180
+			for _, airport := range i.grid[cell] {
+				if d := geo.DistanceKm(lat, lon, airport.Lat, airport.Lon); d <= bestDist {
+					best = airport
+					bestDist = d
+				}
+			}
+		}
+	}
+	return best, bestDist, best != nil
+}
+
+// RunwayFor picks the runway end closest to the track the aircraft was on, within maxDiff degrees
+func (a *Airport) RunwayFor(track float64, maxDiff float64) (Runway, bool) {
+	var (
+		best     Runway
+		bestDiff = maxDiff
+		found    bool
+	)
+	for _, r := range a.Runways {
+		if diff := geo.HeadingDiff(track, r.Heading); diff <= bestDiff {
+			best = r
+			bestDiff = diff
+			found = true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,48 @@
+package airports
+
+import (
+	"strings"
+	"testing"
+)
+
+const TEST_AIRPORTS = `"id","ident","type","name","latitude_deg","longitude_deg","elevation_ft","continent","iso_country","iso_region","municipality","scheduled_service","gps_code","iata_code","local_code","home_link","wikipedia_link","keywords"
+2513,"EHAM","large_airport","Amsterdam Airport Schiphol",52.308601,4.76389,-11,"EU","NL","NL-NH","Amsterdam","yes","EHAM","AMS",,"https://www.schiphol.nl/","https://en.wikipedia.org/wiki/Amsterdam_Airport_Schiphol","AMS, Amsterdam"
+2522,"EHLE","medium_airport","Lelystad Airport",52.4603,5.52722,-13,"EU","NL","NL-FL","Lelystad","yes","EHLE","LEY",,,,
+9999,"XX01","closed","Old Field",52.31,4.77,0,"EU","NL","NL-NH",,"no",,,,,,
+3000,"NZCH","large_airport","Christchurch",-43.4894,172.532,123,"OC","NZ","NZ-CAN","Christchurch","yes","NZCH","CHC",,,,
+`
+
+const TEST_RUNWAYS = `"id","airport_ref","airport_ident","length_ft","width_ft","surface","lighted","closed","le_ident","le_latitude_deg","le_longitude_deg","le_elevation_ft","le_heading_degT","le_displaced_threshold_ft","he_ident","he_latitude_deg","he_longitude_deg","he_elevation_ft","he_heading_degT","he_displaced_threshold_ft"
+1,2513,"EHAM",11483,197,"ASP",1,0,"18R",52.3624,4.71189,-12,183,,"36L",52.3289,4.70911,-12,3,
+2,2513,"EHAM",10499,148,"ASP",1,0,"09",52.3166,4.74635,-11,,,"27",52.318,4.79689,-13,,
+`
+
+func TestNearestAndRunway(t *testing.T) {
+	index, err := LoadAirports(strings.NewReader(TEST_AIRPORTS))
+	if err != nil {
+		t.Fatalf("Expected airports got err %s", err.Error())
+	}
+	if err := index.LoadRunways(strings.NewReader(TEST_RUNWAYS)); err != nil {
+		t.Fatalf("Expected runways got err %s", err.Error())
+	}
+	if index.Len() != 3 {
+		t.Fatalf("Expected the closed airport to be left out got %d", index.Len())
+	}
+	airport, dist, ok := index.Nearest(52.31, 4.77, 5)
+	if ok == false || airport.Ident != "EHAM" || dist > 1 {
+		t.Fatalf("Expected EHAM within 1km got %v %f", airport, dist)
+	}
+	if _, _, ok := index.Nearest(52.0, 3.0, 5); ok {
+		t.Fatalf("Expected nothing within 5km over the sea")
+	}
+	runway, ok := airport.RunwayFor(268, 20)
+	if ok == false || runway.Ident != "27" {
+		t.Fatalf("Expected runway 27 from its number got %v", runway)
+	}
+	if runway, ok := airport.RunwayFor(180, 20); ok == false || runway.Ident != "18R" {
+		t.Fatalf("Expected runway 18R got %v", runway)
+	}
+	if airport, _, ok := index.Nearest(-43.5, 172.55, 10); ok == false || airport.Ident != "NZCH" {
+		t.Fatalf("Expected NZCH in the southern hemisphere got %v", airport)
+	}
+}
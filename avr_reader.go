@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	decoder "github.com/kc8/dump-1090-aggergator/decoder"
+)
+
+// AVR is raw frames as hex, one per line
+// *8D4840D6202CC371C32CE0576098;
+// @0000A3E1B2C38D4840D6202CC371C32CE0576098; the @ variant has a 12 hex digit MLAT timestamp first
+const (
+	AVR_START      byte = '*'
+	AVR_MLAT_START byte = '@'
+	AVR_END        byte = ';'
+
+	AVR_MLAT_TIMESTAMP_LEN = 12
+)
+
+func ParseAVRFormat(msg []byte, received time.Time, modeS *decoder.Decoder) (*FormattedAdbsMsg, error) {
+	line := bytes.TrimSpace(msg)
+	if len(line) < 2 || line[len(line)-1] != AVR_END {
+		return nil, errors.New(fmt.Sprintf("AVR line %q is not terminated with ;", line))
+	}
+	body := line[1 : len(line)-1]
+
+	mlat := Nullable[uint64]{Valid: false}
+	switch line[0] {
+	case AVR_START:
+	case AVR_MLAT_START:
+		if len(body) < AVR_MLAT_TIMESTAMP_LEN {
+			return nil, errors.New(fmt.Sprintf("AVR line %q is missing its MLAT timestamp", line))
+		}
+		timestamp, err := strconv.ParseUint(string(body[:AVR_MLAT_TIMESTAMP_LEN]), 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		mlat = Nullable[uint64]{Value: timestamp, Valid: timestamp != 0}
+		body = body[AVR_MLAT_TIMESTAMP_LEN:]
+	default:
+		return nil, errors.New(fmt.Sprintf("AVR line %q does not start with * or @", line))
+	}
+
+	frame := make([]byte, hex.DecodedLen(len(body)))
+	if _, err := hex.Decode(frame, body); err != nil {
+		return nil, err
+	}
+	decoded, err := modeS.Decode(frame, received)
+	if isSkippableModeSErr(err) {
+		return nil, errFrameSkipped
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := formattedFromModeS(decoded, received)
+	result.MLATTimestamp = mlat
+	return result, nil
+}
+
+func newAVRParser(modeS *decoder.Decoder) lineParser {
+	return func(msg []byte) (*FormattedAdbsMsg, error) {
+		return ParseAVRFormat(msg, time.Now(), modeS)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	decoder "github.com/kc8/dump-1090-aggergator/decoder"
+)
+
+func TestParseAVRFormat(t *testing.T) {
+	msg, err := ParseAVRFormat([]byte("*8D4840D6202CC371C32CE0576098;\r"), time.Now(), decoder.New())
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if msg.AircraftICAOAddr != "4840D6" || msg.CallsignFlightNum != "KLM1023" {
+		t.Fatalf("Expected 4840D6 KLM1023 got %s %s", msg.AircraftICAOAddr, msg.CallsignFlightNum)
+	}
+	if msg.MLATTimestamp.Valid {
+		t.Fatalf("Expected no MLAT timestamp got %v", msg.MLATTimestamp)
+	}
+}
+
+func TestParseAVRFormatMLAT(t *testing.T) {
+	msg, err := ParseAVRFormat([]byte("@0000A3E1B2C38D40621D58C382D690C8AC2863A7;"), time.Now(), decoder.New())
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if msg.MLATTimestamp.Valid == false || msg.MLATTimestamp.Value != 0xA3E1B2C3 {
+		t.Fatalf("Expected MLAT timestamp 0xA3E1B2C3 got %v", msg.MLATTimestamp)
+	}
+	if msg.Altitude.Valid == false || msg.Altitude.Value != 38000 {
+		t.Fatalf("Expected altitude 38000 got %v", msg.Altitude)
+	}
+	if msg.CPR.Valid == false {
+		t.Fatalf("Expected a CPR frame")
+	}
+}
+
+func TestParseAVRFormatRejectsGarbage(t *testing.T) {
+	for _, line := range []string{"", "8D4840D6202CC371C32CE0576098", "*8D4840D6202CC371C32CE05760;", "*ZZ;"} {
+		if _, err := ParseAVRFormat([]byte(line), time.Now(), decoder.New()); err == nil {
+			t.Fatalf("Expected an error for %q", line)
+		}
+	}
+}
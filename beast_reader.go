@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	decoder "github.com/kc8/dump-1090-aggergator/decoder"
+)
+
+// Beast binary protocol, see https://github.com/firestuff/adsb-tools/blob/master/protocols/beast.md
+// <0x1a> <type> <6 byte 12MHz MLAT timestamp> <1 byte signal level> <frame>
+// every 0x1a inside the timestamp, signal or frame is escaped as 0x1a 0x1a
+const (
+	BEAST_ESCAPE            byte = 0x1a
+	BEAST_TYPE_MODE_AC      byte = 0x31
+	BEAST_TYPE_MODE_S_SHORT byte = 0x32
+	BEAST_TYPE_MODE_S_LONG  byte = 0x33
+	BEAST_TYPE_STATUS       byte = 0x34
+
+	BEAST_MLAT_TIMESTAMP_LEN = 6
+	BEAST_SIGNAL_LEN         = 1
+)
+
+var (
+	errBeastResync = errors.New("Beast frame interrupted by a new frame, resyncing")
+)
+
+type BeastFrame struct {
+	Type          byte
+	MLATTimestamp uint64 // 12MHz counter from the receiver
+	Signal        byte
+	Data          []byte
+}
+
+func beastFrameLen(frameType byte) (int, error) {
+	switch frameType {
+	case BEAST_TYPE_MODE_AC:
+		return 2, nil
+	case BEAST_TYPE_MODE_S_SHORT:
+		return 7, nil
+	case BEAST_TYPE_MODE_S_LONG:
+		return 14, nil
+	case BEAST_TYPE_STATUS:
+		return 14, nil
+	}
+	return 0, errors.New(fmt.Sprintf("Unknown beast frame type 0x%02x", frameType))
+}
+
+// readBeastByte un-escapes a single byte from inside a frame
+func readBeastByte(r *bufio.Reader) (byte, error) {
+	peeked, err := r.Peek(1)
+	if err != nil {
+		return 0, err
+	}
+	if peeked[0] == BEAST_ESCAPE {
+		peeked, err = r.Peek(2)
+		if err != nil {
+			return 0, err
+		}
+		if peeked[1] != BEAST_ESCAPE {
+			// an un-escaped 0x1a is the start of the next frame, leave it for the caller to resync on
+			return 0, errBeastResync
+		}
+		r.Discard(1)
+	}
+	return r.ReadByte()
+}
+
+// readBeastFrame blocks until a full frame is read, skipping bytes until it is in sync with a frame start
+func readBeastFrame(r *bufio.Reader) (*BeastFrame, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != BEAST_ESCAPE {
+			continue
+		}
+		frameType, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if frameType == BEAST_ESCAPE {
+			// escaped data byte, we are not at the start of a frame
+			continue
+		}
+		dataLen, lenErr := beastFrameLen(frameType)
+		if lenErr != nil {
+			continue
+		}
+
+		raw := make([]byte, BEAST_MLAT_TIMESTAMP_LEN+BEAST_SIGNAL_LEN+dataLen)
+		for i := range raw {
+			raw[i], err = readBeastByte(r)
+			if err != nil {
+				return nil, err
+			}
+		}
+		var mlat uint64
+		for _, t := range raw[:BEAST_MLAT_TIMESTAMP_LEN] {
+			mlat = mlat<<8 | uint64(t)
+		}
+		return &BeastFrame{
+			Type:          frameType,
+			MLATTimestamp: mlat,
+			Signal:        raw[BEAST_MLAT_TIMESTAMP_LEN],
+			Data:          raw[BEAST_MLAT_TIMESTAMP_LEN+BEAST_SIGNAL_LEN:],
+		}, nil
+	}
+}
+
+// signalLevelToDbfs uses the same scaling as dump1090, the byte is sqrt(signal power) * 255
+func signalLevelToDbfs(signal byte) Nullable[float32] {
+	if signal == 0 {
+		return Nullable[float32]{Valid: false}
+	}
+	level := float64(signal) / 255
+	return Nullable[float32]{Value: float32(20 * math.Log10(level)), Valid: true}
+}
+
+func ParseBeastFrame(frame *BeastFrame, received time.Time, modeS *decoder.Decoder) (*FormattedAdbsMsg, error) {
+	if frame.Type != BEAST_TYPE_MODE_S_SHORT && frame.Type != BEAST_TYPE_MODE_S_LONG {
+		return nil, errFrameSkipped
+	}
+	decoded, err := modeS.Decode(frame.Data, received)
+	if isSkippableModeSErr(err) {
+		return nil, errFrameSkipped
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := formattedFromModeS(decoded, received)
+	result.MLATTimestamp = Nullable[uint64]{Value: frame.MLATTimestamp, Valid: frame.MLATTimestamp != 0}
+	result.SignalLevel = signalLevelToDbfs(frame.Signal)
+	return result, nil
+}
+
+func readBeastData(
+	ctx context.Context,
+	rcv receiver,
+	itemQueue *modifyStoQueue) {
+	// known addresses outlive a reconnect
+	modeS := decoder.New()
+	superviseConnection(ctx, rcv, func(conn net.Conn) error {
+		return consumeBeast(bufio.NewReader(conn), rcv, modeS, itemQueue)
+	})
+}
+
+// handleBeastFrame records, parses and queues one frame of a beast feed
+func handleBeastFrame(frame *BeastFrame, rcv receiver, modeS *decoder.Decoder, itemQueue *modifyStoQueue) {
+	received := time.Now()
+	recordFeed(received, rcv, encodeBeastRecord(frame))
+	result, err := ParseBeastFrame(frame, received, modeS)
+	if errors.Is(err, errFrameSkipped) {
+		return
+	}
+	if err != nil {
+		Log(fmt.Sprintf("Failed to parse beast frame due to: %s", err.Error()), ERROR)
+		return
+	}
+	result.ReceiverId = rcv.Id
+	itemQueue.updateOrAdd(result)
+}
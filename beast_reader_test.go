@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	decoder "github.com/kc8/dump-1090-aggergator/decoder"
+)
+
+func TestReadBeastFrame(t *testing.T) {
+	frame, _ := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	raw := []byte{0x00, 0x1a, BEAST_TYPE_MODE_S_LONG}
+	// timestamp with an escaped 0x1a in it
+	raw = append(raw, 0x00, 0x00, 0x1a, 0x1a, 0x00, 0x01, 0x02)
+	raw = append(raw, 0x80) // signal
+	raw = append(raw, frame...)
+
+	result, err := readBeastFrame(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("Expected frame got err %s", err.Error())
+	}
+	if result.MLATTimestamp != 0x1a000102 {
+		t.Fatalf("Expected mlat timestamp 0x1a000102 got 0x%x", result.MLATTimestamp)
+	}
+	if bytes.Equal(result.Data, frame) == false {
+		t.Fatalf("Expected frame %x got %x", frame, result.Data)
+	}
+
+	msg, err := ParseBeastFrame(result, time.Now(), decoder.New())
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if msg.AircraftICAOAddr != "4840D6" {
+		t.Fatalf("Expected icao 4840D6 got %s", msg.AircraftICAOAddr)
+	}
+	if msg.CallsignFlightNum != "KLM1023" {
+		t.Fatalf("Expected callsign KLM1023 got %s", msg.CallsignFlightNum)
+	}
+	if msg.SignalLevel.Valid == false || msg.SignalLevel.Value > 0 {
+		t.Fatalf("Expected a negative dBFS signal level got %v", msg.SignalLevel)
+	}
+}
+
+func TestReadBeastFrameResync(t *testing.T) {
+	frame, _ := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	// a truncated short frame followed by a full long frame
+	raw := []byte{0x1a, BEAST_TYPE_MODE_S_SHORT, 0x00, 0x00}
+	raw = append(raw, 0x1a, BEAST_TYPE_MODE_S_LONG, 0, 0, 0, 0, 0, 1, 0x40)
+	raw = append(raw, frame...)
+	reader := bufio.NewReader(bytes.NewReader(raw))
+
+	if _, err := readBeastFrame(reader); err != errBeastResync {
+		t.Fatalf("Expected resync error got %v", err)
+	}
+	result, err := readBeastFrame(reader)
+	if err != nil {
+		t.Fatalf("Expected frame after resync got err %s", err.Error())
+	}
+	if bytes.Equal(result.Data, frame) == false {
+		t.Fatalf("Expected frame %x got %x", frame, result.Data)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+// bumped when CollectedData changes in a way an older checkpoint can not be read into
+const CHECKPOINT_VERSION = 1
+
+// checkpoint is every aircraft still in storage when the collector stopped
+type checkpoint struct {
+	Version  int                              `json:"version"`
+	SavedAt  int64                            `json:"savedAt"`
+	Aircraft []storage.MapItem[CollectedData] `json:"aircraft"`
+}
+
+// writeCheckpoint saves items to path, it writes a temporary file first so a crash part way
+// through leaves the previous checkpoint in place
+func writeCheckpoint(path string, items []storage.MapItem[CollectedData], savedAt int64) error {
+	b, err := json.Marshal(checkpoint{Version: CHECKPOINT_VERSION, SavedAt: savedAt, Aircraft: items})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readCheckpoint returns nothing and no error when there is no checkpoint at path
+func readCheckpoint(path string) (checkpoint, error) {
+	var saved checkpoint
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return saved, nil
+	}
+	if err != nil {
+		return saved, err
+	}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return saved, err
+	}
+	if saved.Version != CHECKPOINT_VERSION {
+		return checkpoint{}, errors.New(fmt.Sprintf("Checkpoint %s is version %d expected %d", path, saved.Version, CHECKPOINT_VERSION))
+	}
+	return saved, nil
+}
+
+// restoreCheckpoint puts the aircraft saved at path back into sto so flights carry on where they
+// were when the collector stopped, the file is removed so it is only restored once
+func restoreCheckpoint(path string, sto *storage.MapStorage[CollectedData]) {
+	if path == "" {
+		return
+	}
+	saved, err := readCheckpoint(path)
+	if err != nil {
+		Log(fmt.Sprintf("Could not restore checkpoint: %s", err.Error()), FATAL)
+	}
+	for _, item := range saved.Aircraft {
+		if err := sto.Insert(item, simpleKeyCompare); err != nil {
+			Log(fmt.Sprintf("Could not restore %s: %s", item.Key, err.Error()), ERROR)
+		}
+	}
+	if saved.SavedAt == 0 {
+		return
+	}
+	Log(fmt.Sprintf("Restored %d aircraft from %s saved %s ago", len(saved.Aircraft), path,
+		time.Since(time.UnixMilli(saved.SavedAt)).Round(time.Second)), INFO)
+	if err := os.Remove(path); err != nil {
+		Log(fmt.Sprintf("Could not remove checkpoint %s: %s", path, err.Error()), WARN)
+	}
+}
+
+// shutdown runs once the feeds have stopped, it waits for the queue to empty and then saves every
+// aircraft to checkpointPath, or hands them to the writer when there is no checkpoint, and waits
+// for the writer to finish
+func shutdown(writer *flightWriter, itemQueue *modifyStoQueue, checkpointPath string) {
+	itemQueue.sync()
+	saved := false
+	if checkpointPath != "" {
+		items := itemQueue.snapshot()
+		if err := writeCheckpoint(checkpointPath, items, time.Now().UTC().UnixMilli()); err != nil {
+			Log(fmt.Sprintf("Could not write checkpoint, writing to the database instead: %s", err.Error()), ERROR)
+		} else {
+			Log(fmt.Sprintf("Saved %d aircraft to %s", len(items), checkpointPath), INFO)
+			saved = true
+		}
+	}
+	if saved == false {
+		writeReady(itemQueue, writer, func(item storage.MapItem[CollectedData]) bool {
+			return true
+		})
+	}
+	writer.close()
+	Log(fmt.Sprintf("Wrote %d aircraft, %d went to the dead letter file", writer.written, writer.deadLettered), INFO)
+	if err := writer.sink.Clean(); err != nil {
+		Log(fmt.Sprintf("Failed to close database: %s", err.Error()), ERROR)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+	database "github.com/kc8/dump-1090-aggergator/storage/database"
+)
+
+func TestCheckpointStitchesFlightsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "aircraft.checkpoint")
+	db, err := database.New("checkpoint.db", dir)
+	if err != nil {
+		t.Fatalf("Expected database got err %s", err.Error())
+	}
+
+	msg, _ := ParseCSVFormat([]byte(TEST_SBS_LINE))
+	store := storage.NewMapStorage[CollectedData]()
+	q := NewQueue(&store)
+	go q.run(make(chan Nullable[storage.MapItem[CollectedData]]))
+	defer q.stop()
+	item := aggregate(storage.MapItem[CollectedData]{}, false, msg, 1000)[0]
+	item = aggregate(item, true, msg, 2000)[0]
+	q.append(item)
+	shutdown(newFlightWriter(db, testWriting(dir)), q, path)
+
+	restored := storage.NewMapStorage[CollectedData]()
+	restoreCheckpoint(path, &restored)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) == false {
+		t.Fatalf("Expected the checkpoint to be removed once restored")
+	}
+	item, err = restored.Search(msg.AircraftICAOAddr, simpleKeyCompare)
+	if err != nil {
+		t.Fatalf("Expected %s to be restored got err %s", msg.AircraftICAOAddr, err.Error())
+	}
+	item = aggregate(item, true, msg, 3000)[0]
+	if item.Data.FirstSeen != 1000 || item.Data.LastSeen != 3000 {
+		t.Fatalf("Expected one flight from 1000 to 3000 got %d to %d", item.Data.FirstSeen, item.Data.LastSeen)
+	}
+
+	db, _ = database.New("checkpoint.db", dir)
+	defer db.Clean()
+	flights, _ := db.FindFlights(context.Background(), database.FlightFilter{Airport: ""})
+	if len(flights) != 0 {
+		t.Fatalf("Expected nothing written to the database when checkpointing got %v", flights)
+	}
+}
@@ -0,0 +1,151 @@
+package decoder
+
+import (
+	"math"
+)
+
+// Compact Position Reporting, see https://mode-s.org/decode/content/ads-b/3-airborne-position.html
+// and https://mode-s.org/decode/content/ads-b/4-surface-position.html
+
+const (
+	CPR_MAX    = 131072 // 2^17
+	CPR_NZ     = 15
+	CPR_AIR    = 360.0
+	CPR_GROUND = 90.0
+)
+
+// NL is the number of longitude zones at a latitude
+func NL(lat float64) int {
+	lat = math.Abs(lat)
+	if lat == 0 {
+		return 59
+	}
+	if lat == 87 {
+		return 2
+	}
+	if lat > 87 {
+		return 1
+	}
+	a := 1 - math.Cos(math.Pi/(2*CPR_NZ))
+	b := math.Pow(math.Cos(math.Pi/180*lat), 2)
+	return int(math.Floor(2 * math.Pi / math.Acos(1-a/b)))
+}
+
+// mod is always positive unlike math.Mod
+func mod(a float64, b float64) float64 {
+	result := math.Mod(a, b)
+	if result < 0 {
+		result += b
+	}
+	return result
+}
+
+func cprZone(frame CPRFrame) float64 {
+	if frame.Surface {
+		return CPR_GROUND
+	}
+	return CPR_AIR
+}
+
+func normalizeLon(lon float64) float64 {
+	if lon >= 180 {
+		return lon - 360
+	}
+	return lon
+}
+
+// closest picks the candidate nearest to the reference, surface positions repeat every 90 degrees
+func closest(value float64, reference float64, step float64) float64 {
+	best := value
+	for candidate := value - 360; candidate <= value+360; candidate += step {
+		if math.Abs(candidate-reference) < math.Abs(best-reference) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// GlobalPosition resolves a lat/long from an even and odd frame of the same kind, latest is whichever
+// of the two was received last. Surface frames only resolve within a quadrant so they need a reference
+// position within 45 NM, refLat and refLon are ignored for airborne frames.
+func GlobalPosition(even CPRFrame, odd CPRFrame, latest CPRFrame, refLat float64, refLon float64) (float64, float64, bool) {
+	if even.Surface != odd.Surface || even.Odd || odd.Odd == false {
+		return 0, 0, false
+	}
+	zone := cprZone(even)
+	latCprEven := float64(even.Lat) / CPR_MAX
+	lonCprEven := float64(even.Lon) / CPR_MAX
+	latCprOdd := float64(odd.Lat) / CPR_MAX
+	lonCprOdd := float64(odd.Lon) / CPR_MAX
+
+	dLatEven := zone / 60
+	dLatOdd := zone / 59
+	j := math.Floor(59*latCprEven - 60*latCprOdd + 0.5)
+	latEven := dLatEven * (mod(j, 60) + latCprEven)
+	latOdd := dLatOdd * (mod(j, 59) + latCprOdd)
+	if even.Surface {
+		// the northern hemisphere solution, the southern one is 90 degrees below it
+		latEven = closest(latEven, refLat, 90)
+		latOdd = closest(latOdd, refLat, 90)
+	} else {
+		if latEven >= 270 {
+			latEven -= 360
+		}
+		if latOdd >= 270 {
+			latOdd -= 360
+		}
+	}
+	if latEven < -90 || latEven > 90 || latOdd < -90 || latOdd > 90 {
+		return 0, 0, false
+	}
+	// the pair straddles a longitude zone boundary and cannot be used together
+	if NL(latEven) != NL(latOdd) {
+		return 0, 0, false
+	}
+
+	lat := latEven
+	lonCpr := lonCprEven
+	ni := NL(latEven)
+	if latest.Odd {
+		lat = latOdd
+		lonCpr = lonCprOdd
+		ni = NL(latOdd) - 1
+	}
+	if ni < 1 {
+		ni = 1
+	}
+	nl := float64(NL(lat))
+	m := math.Floor(lonCprEven*(nl-1) - lonCprOdd*nl + 0.5)
+	lon := (zone / float64(ni)) * (mod(m, float64(ni)) + lonCpr)
+	if even.Surface {
+		return lat, closest(lon, refLon, 90), true
+	}
+	return lat, normalizeLon(lon), true
+}
+
+// LocalPosition resolves a single frame against a reference position, the reference has to be
+// within 180 NM for airborne frames and 45 NM for surface frames for the result to be right
+func LocalPosition(frame CPRFrame, refLat float64, refLon float64) (float64, float64) {
+	zone := cprZone(frame)
+	latCpr := float64(frame.Lat) / CPR_MAX
+	lonCpr := float64(frame.Lon) / CPR_MAX
+
+	dLat := zone / 60
+	if frame.Odd {
+		dLat = zone / 59
+	}
+	j := math.Floor(refLat/dLat) + math.Floor(mod(refLat, dLat)/dLat-latCpr+0.5)
+	lat := dLat * (j + latCpr)
+
+	ni := NL(lat)
+	if frame.Odd {
+		ni--
+	}
+	if ni < 1 {
+		ni = 1
+	}
+	dLon := zone / float64(ni)
+	m := math.Floor(refLon/dLon) + math.Floor(mod(refLon, dLon)/dLon-lonCpr+0.5)
+	lon := dLon * (m + lonCpr)
+	return lat, lon
+}
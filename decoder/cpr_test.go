@@ -0,0 +1,90 @@
+package decoder
+
+import (
+	"math"
+	"testing"
+)
+
+// encodeCPR is the inverse of LocalPosition, used to build frames for positions without a published example
+func encodeCPR(lat float64, lon float64, odd bool, surface bool) CPRFrame {
+	zone := CPR_AIR
+	if surface {
+		zone = CPR_GROUND
+	}
+	dLat := zone / 60
+	if odd {
+		dLat = zone / 59
+	}
+	yz := math.Floor(CPR_MAX*mod(lat, dLat)/dLat + 0.5)
+	rLat := dLat * (yz/CPR_MAX + math.Floor(lat/dLat))
+	ni := NL(rLat)
+	if odd {
+		ni--
+	}
+	if ni < 1 {
+		ni = 1
+	}
+	dLon := zone / float64(ni)
+	xz := math.Floor(CPR_MAX*mod(lon, dLon)/dLon + 0.5)
+	return CPRFrame{
+		Odd:     odd,
+		Surface: surface,
+		Lat:     uint32(mod(yz, CPR_MAX)),
+		Lon:     uint32(mod(xz, CPR_MAX)),
+	}
+}
+
+func TestNL(t *testing.T) {
+	cases := map[float64]int{0: 59, 10: 59, 52.2572: 36, 87: 2, 88: 1, -52.2572: 36}
+	for lat, expected := range cases {
+		if nl := NL(lat); nl != expected {
+			t.Fatalf("Expected NL(%f) to be %d got %d", lat, expected, nl)
+		}
+	}
+}
+
+func TestGlobalAirbornePosition(t *testing.T) {
+	even := CPRFrame{Odd: false, Lat: 93000, Lon: 51372}
+	odd := CPRFrame{Odd: true, Lat: 74158, Lon: 50194}
+	lat, lon, ok := GlobalPosition(even, odd, even, 0, 0)
+	if ok == false {
+		t.Fatalf("Expected a global position")
+	}
+	if math.Abs(lat-52.25720) > 0.0001 || math.Abs(lon-3.91937) > 0.0001 {
+		t.Fatalf("Expected 52.25720, 3.91937 got %f, %f", lat, lon)
+	}
+}
+
+func TestLocalAirbornePosition(t *testing.T) {
+	even := CPRFrame{Odd: false, Lat: 93000, Lon: 51372}
+	lat, lon := LocalPosition(even, 52.258, 3.918)
+	if math.Abs(lat-52.25720) > 0.0001 || math.Abs(lon-3.91937) > 0.0001 {
+		t.Fatalf("Expected 52.25720, 3.91937 got %f, %f", lat, lon)
+	}
+}
+
+func TestGlobalSurfacePosition(t *testing.T) {
+	lat, lon := 52.32061, 4.73473
+	even := encodeCPR(lat, lon, false, true)
+	odd := encodeCPR(lat, lon, true, true)
+	decodedLat, decodedLon, ok := GlobalPosition(even, odd, odd, 51.990, 4.375)
+	if ok == false {
+		t.Fatalf("Expected a global surface position")
+	}
+	if math.Abs(decodedLat-lat) > 0.0001 || math.Abs(decodedLon-lon) > 0.0001 {
+		t.Fatalf("Expected %f, %f got %f, %f", lat, lon, decodedLat, decodedLon)
+	}
+}
+
+func TestGlobalPositionSouthWest(t *testing.T) {
+	lat, lon := -33.9425, -118.4081
+	even := encodeCPR(lat, lon, false, false)
+	odd := encodeCPR(lat, lon, true, false)
+	decodedLat, decodedLon, ok := GlobalPosition(even, odd, even, 0, 0)
+	if ok == false {
+		t.Fatalf("Expected a global position")
+	}
+	if math.Abs(decodedLat-lat) > 0.001 || math.Abs(decodedLon-lon) > 0.001 {
+		t.Fatalf("Expected %f, %f got %f, %f", lat, lon, decodedLat, decodedLon)
+	}
+}
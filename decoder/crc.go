@@ -0,0 +1,37 @@
+package decoder
+
+// Mode S parity uses a 24 bit CRC with the generator polynomial 0x1FFF409
+const CRC_GENERATOR uint32 = 0xFFF409
+
+var crcTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := 0; i < 256; i++ {
+		crc := uint32(i) << 16
+		for j := 0; j < 8; j++ {
+			if crc&0x800000 != 0 {
+				crc = (crc << 1) ^ CRC_GENERATOR
+			} else {
+				crc = crc << 1
+			}
+		}
+		table[i] = crc & 0xFFFFFF
+	}
+	return table
+}()
+
+// Crc computes the 24 bit parity of data, data should not include the parity field
+func Crc(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = ((crc << 8) ^ crcTable[byte(crc>>16)^b]) & 0xFFFFFF
+	}
+	return crc
+}
+
+// Residual xors the computed parity with the parity field at the end of frame,
+// this is 0 for a good DF11/17/18 and the aircraft address for DF4/5/20/21
+func Residual(frame []byte) uint32 {
+	n := len(frame) - 3
+	parity := uint32(frame[n])<<16 | uint32(frame[n+1])<<8 | uint32(frame[n+2])
+	return Crc(frame[:n]) ^ parity
+}
@@ -0,0 +1,289 @@
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Decoding of raw 56/112 bit Mode S frames, see https://mode-s.org/decode/content/ads-b/1-basics.html
+
+const (
+	SHORT_FRAME_LEN = 7
+	LONG_FRAME_LEN  = 14
+
+	// how long an address seen in a DF11/17/18 is trusted for DF4/5/20/21 replies
+	KNOWN_ADDRESS_TTL = 60 * time.Second
+)
+
+var (
+	ErrBadCrc         = errors.New("Mode S frame failed its parity check")
+	ErrUnknownAddress = errors.New("Mode S reply is from an address that has not been seen in a squitter")
+	ErrUnsupported    = errors.New("Mode S downlink format or type code is not decoded")
+)
+
+type Nullable[T any] struct {
+	Value T
+	Valid bool
+}
+
+// CPRFrame is an undecoded compact position report, it takes an odd and even pair
+// or a reference position to turn this into a lat/long
+type CPRFrame struct {
+	Odd     bool
+	Surface bool
+	Lat     uint32 // 17 bit
+	Lon     uint32 // 17 bit
+}
+
+type Message struct {
+	DownlinkFormat int
+	TypeCode       int // only for extended squitters
+	Icao           string
+
+	Callsign     string
+	Altitude     Nullable[int]     // feet
+	GroundSpeed  Nullable[float64] // knots
+	Track        Nullable[float64] // degrees
+	VerticalRate Nullable[int]     // feet per minute
+	Squawk       Nullable[int]
+	OnGround     Nullable[bool]
+	Alert        Nullable[bool] // squawk changed
+	SPI          Nullable[bool] // ident
+	Emergency    Nullable[bool]
+	CPR          Nullable[CPRFrame]
+}
+
+// Decoder keeps track of addresses it has seen in the clear so replies that only carry
+// an address in their parity can be trusted
+type Decoder struct {
+	knownAddresses map[uint32]time.Time
+	lastPrune      time.Time
+	mutex          sync.Mutex
+}
+
+func New() *Decoder {
+	return &Decoder{
+		knownAddresses: make(map[uint32]time.Time),
+	}
+}
+
+func (d *Decoder) markKnown(addr uint32, received time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.knownAddresses[addr] = received
+	if received.Sub(d.lastPrune) > KNOWN_ADDRESS_TTL {
+		for k, seen := range d.knownAddresses {
+			if received.Sub(seen) > KNOWN_ADDRESS_TTL {
+				delete(d.knownAddresses, k)
+			}
+		}
+		d.lastPrune = received
+	}
+}
+
+func (d *Decoder) isKnown(addr uint32, received time.Time) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	seen, ok := d.knownAddresses[addr]
+	return ok && received.Sub(seen) <= KNOWN_ADDRESS_TTL
+}
+
+func frameLen(downlinkFormat int) int {
+	if downlinkFormat >= 16 {
+		return LONG_FRAME_LEN
+	}
+	return SHORT_FRAME_LEN
+}
+
+func formatIcao(addr uint32) string {
+	return fmt.Sprintf("%06X", addr)
+}
+
+// Decode a single frame, received is used to expire addresses that have not been heard from
+func (d *Decoder) Decode(frame []byte, received time.Time) (*Message, error) {
+	if len(frame) < SHORT_FRAME_LEN {
+		return nil, errors.New(fmt.Sprintf("Mode S frame too short, got %d bytes", len(frame)))
+	}
+	downlinkFormat := int(getBits(frame, 1, 5))
+	if len(frame) != frameLen(downlinkFormat) {
+		return nil, errors.New(fmt.Sprintf("Mode S DF%d expected %d bytes got %d", downlinkFormat, frameLen(downlinkFormat), len(frame)))
+	}
+	result := &Message{DownlinkFormat: downlinkFormat}
+
+	switch downlinkFormat {
+	case 11:
+		// the low 7 bits can be the interrogator id overlaid on the parity
+		if Residual(frame)&0xFFFF80 != 0 {
+			return nil, ErrBadCrc
+		}
+		addr := getBits(frame, 9, 32)
+		d.markKnown(addr, received)
+		result.Icao = formatIcao(addr)
+		decodeCapability(frame, result)
+		return result, nil
+	case 17, 18:
+		if Residual(frame) != 0 {
+			return nil, ErrBadCrc
+		}
+		if downlinkFormat == 18 {
+			// only ADS-B (0) and ADS-R (6) are sent with an ICAO address
+			controlField := getBits(frame, 6, 8)
+			if controlField != 0 && controlField != 6 {
+				return nil, ErrUnsupported
+			}
+		} else {
+			decodeCapability(frame, result)
+		}
+		addr := getBits(frame, 9, 32)
+		d.markKnown(addr, received)
+		result.Icao = formatIcao(addr)
+		decodeExtendedSquitter(frame, result)
+		return result, nil
+	case 4, 5, 20, 21:
+		addr := Residual(frame)
+		if d.isKnown(addr, received) == false {
+			return nil, ErrUnknownAddress
+		}
+		result.Icao = formatIcao(addr)
+		decodeFlightStatus(getBits(frame, 6, 8), result)
+		if downlinkFormat == 4 || downlinkFormat == 20 {
+			altitude := decodeAC13Field(getBits(frame, 20, 32))
+			if altitude != INVALID_ALTITUDE {
+				result.Altitude = Nullable[int]{Value: altitude, Valid: true}
+			}
+		} else {
+			result.Squawk = Nullable[int]{Value: squawkFromID13(getBits(frame, 20, 32)), Valid: true}
+		}
+		return result, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// decodeCapability reads the CA field of DF11/17 which tells if the aircraft is on the ground
+func decodeCapability(frame []byte, result *Message) {
+	switch getBits(frame, 6, 8) {
+	case 4:
+		result.OnGround = Nullable[bool]{Value: true, Valid: true}
+	case 5:
+		result.OnGround = Nullable[bool]{Value: false, Valid: true}
+	}
+}
+
+func decodeFlightStatus(flightStatus uint32, result *Message) {
+	switch flightStatus {
+	case 0, 2:
+		result.OnGround = Nullable[bool]{Value: false, Valid: true}
+	case 1, 3:
+		result.OnGround = Nullable[bool]{Value: true, Valid: true}
+	}
+	if flightStatus > 5 {
+		return
+	}
+	alert := flightStatus >= 2 && flightStatus <= 4
+	result.Alert = Nullable[bool]{Value: alert, Valid: true}
+	spi := flightStatus == 4 || flightStatus == 5
+	result.SPI = Nullable[bool]{Value: spi, Valid: true}
+}
+
+// type codes that are not decoded still return the address so the aircraft is counted as seen
+func decodeExtendedSquitter(frame []byte, result *Message) {
+	typeCode := int(getMEBits(frame, 1, 5))
+	result.TypeCode = typeCode
+
+	switch {
+	case typeCode >= 1 && typeCode <= 4:
+		result.Callsign = decodeCallsign(frame)
+	case typeCode >= 5 && typeCode <= 8:
+		result.OnGround = Nullable[bool]{Value: true, Valid: true}
+		if speed, ok := decodeMovement(getMEBits(frame, 6, 12)); ok {
+			result.GroundSpeed = Nullable[float64]{Value: speed, Valid: true}
+		}
+		if getMEBits(frame, 13, 13) == 1 {
+			track := float64(getMEBits(frame, 14, 20)) * 360 / 128
+			result.Track = Nullable[float64]{Value: track, Valid: true}
+		}
+		result.CPR = decodeCPRFrame(frame, true)
+	case typeCode >= 9 && typeCode <= 18:
+		result.OnGround = Nullable[bool]{Value: false, Valid: true}
+		altitude := decodeAC12Field(getMEBits(frame, 9, 20))
+		if altitude != INVALID_ALTITUDE {
+			result.Altitude = Nullable[int]{Value: altitude, Valid: true}
+		}
+		result.CPR = decodeCPRFrame(frame, false)
+	case typeCode == 19:
+		result.OnGround = Nullable[bool]{Value: false, Valid: true}
+		decodeVelocity(frame, result)
+	case typeCode >= 20 && typeCode <= 22:
+		// GNSS height is not comparable to the barometric altitude everything else reports, only keep the position
+		result.OnGround = Nullable[bool]{Value: false, Valid: true}
+		result.CPR = decodeCPRFrame(frame, false)
+	case typeCode == 28:
+		if getMEBits(frame, 6, 8) != 1 {
+			return
+		}
+		result.Emergency = Nullable[bool]{Value: getMEBits(frame, 9, 11) != 0, Valid: true}
+		result.Squawk = Nullable[int]{Value: squawkFromID13(getMEBits(frame, 12, 24)), Valid: true}
+	}
+}
+
+func decodeCPRFrame(frame []byte, surface bool) Nullable[CPRFrame] {
+	return Nullable[CPRFrame]{
+		Value: CPRFrame{
+			Odd:     getMEBits(frame, 22, 22) == 1,
+			Surface: surface,
+			Lat:     getMEBits(frame, 23, 39),
+			Lon:     getMEBits(frame, 40, 56),
+		},
+		Valid: true,
+	}
+}
+
+func decodeVelocity(frame []byte, result *Message) {
+	subType := getMEBits(frame, 6, 8)
+	switch subType {
+	case 1, 2:
+		eastWest := getMEBits(frame, 15, 24)
+		northSouth := getMEBits(frame, 26, 35)
+		if eastWest != 0 && northSouth != 0 {
+			multiplier := 1.0
+			if subType == 2 { // supersonic
+				multiplier = 4
+			}
+			vEastWest := float64(eastWest-1) * multiplier
+			if getMEBits(frame, 14, 14) == 1 {
+				vEastWest = -vEastWest
+			}
+			vNorthSouth := float64(northSouth-1) * multiplier
+			if getMEBits(frame, 25, 25) == 1 {
+				vNorthSouth = -vNorthSouth
+			}
+			speed := math.Sqrt(vEastWest*vEastWest + vNorthSouth*vNorthSouth)
+			track := math.Atan2(vEastWest, vNorthSouth) * 180 / math.Pi
+			if track < 0 {
+				track += 360
+			}
+			result.GroundSpeed = Nullable[float64]{Value: speed, Valid: true}
+			result.Track = Nullable[float64]{Value: track, Valid: true}
+		}
+	case 3, 4:
+		// airspeed and heading, heading is the closest we get to a track for these
+		if getMEBits(frame, 14, 14) == 1 {
+			heading := float64(getMEBits(frame, 15, 24)) * 360 / 1024
+			result.Track = Nullable[float64]{Value: heading, Valid: true}
+		}
+	default:
+		return
+	}
+
+	verticalRate := getMEBits(frame, 38, 46)
+	if verticalRate != 0 {
+		rate := int(verticalRate-1) * 64
+		if getMEBits(frame, 37, 37) == 1 {
+			rate = -rate
+		}
+		result.VerticalRate = Nullable[int]{Value: rate, Valid: true}
+	}
+}
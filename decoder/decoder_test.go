@@ -0,0 +1,133 @@
+package decoder
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+)
+
+// frames are the worked examples from https://mode-s.org/decode
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("Bad hex in test %s", s)
+	}
+	return b
+}
+
+// surveillanceFrame appends the address overlaid parity to the first 32 bits of a DF4/5 reply
+func surveillanceFrame(first32 uint32, addr uint32) []byte {
+	data := []byte{byte(first32 >> 24), byte(first32 >> 16), byte(first32 >> 8), byte(first32)}
+	parity := Crc(data) ^ addr
+	return append(data, byte(parity>>16), byte(parity>>8), byte(parity))
+}
+
+func TestDecodeIdentification(t *testing.T) {
+	msg, err := New().Decode(mustDecodeHex(t, "8D4840D6202CC371C32CE0576098"), time.Now())
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if msg.Icao != "4840D6" {
+		t.Fatalf("Expected icao 4840D6 got %s", msg.Icao)
+	}
+	if msg.TypeCode != 4 {
+		t.Fatalf("Expected type code 4 got %d", msg.TypeCode)
+	}
+	if msg.Callsign != "KLM1023" {
+		t.Fatalf("Expected callsign KLM1023 got %q", msg.Callsign)
+	}
+}
+
+func TestDecodeAirbornePosition(t *testing.T) {
+	d := New()
+	even, err := d.Decode(mustDecodeHex(t, "8D40621D58C382D690C8AC2863A7"), time.Now())
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if even.Altitude.Valid == false || even.Altitude.Value != 38000 {
+		t.Fatalf("Expected altitude 38000 got %v", even.Altitude)
+	}
+	if even.CPR.Valid == false || even.CPR.Value.Odd || even.CPR.Value.Lat != 93000 || even.CPR.Value.Lon != 51372 {
+		t.Fatalf("Expected even cpr 93000 51372 got %v", even.CPR)
+	}
+	odd, err := d.Decode(mustDecodeHex(t, "8D40621D58C386435CC412692AD6"), time.Now())
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if odd.CPR.Valid == false || odd.CPR.Value.Odd == false || odd.CPR.Value.Lat != 74158 || odd.CPR.Value.Lon != 50194 {
+		t.Fatalf("Expected odd cpr 74158 50194 got %v", odd.CPR)
+	}
+}
+
+func TestDecodeVelocity(t *testing.T) {
+	msg, err := New().Decode(mustDecodeHex(t, "8D485020994409940838175B284F"), time.Now())
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if msg.GroundSpeed.Valid == false || math.Abs(msg.GroundSpeed.Value-159.20) > 0.01 {
+		t.Fatalf("Expected ground speed 159.20 got %v", msg.GroundSpeed)
+	}
+	if msg.Track.Valid == false || math.Abs(msg.Track.Value-182.88) > 0.01 {
+		t.Fatalf("Expected track 182.88 got %v", msg.Track)
+	}
+	if msg.VerticalRate.Valid == false || msg.VerticalRate.Value != -832 {
+		t.Fatalf("Expected vertical rate -832 got %v", msg.VerticalRate)
+	}
+
+	airspeed, err := New().Decode(mustDecodeHex(t, "8DA05F219B06B6AF189400CBC33F"), time.Now())
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if airspeed.Track.Valid == false || math.Abs(airspeed.Track.Value-243.98) > 0.01 {
+		t.Fatalf("Expected heading 243.98 got %v", airspeed.Track)
+	}
+	if airspeed.VerticalRate.Valid == false || airspeed.VerticalRate.Value != -2304 {
+		t.Fatalf("Expected vertical rate -2304 got %v", airspeed.VerticalRate)
+	}
+}
+
+func TestDecodeBadCrc(t *testing.T) {
+	frame := mustDecodeHex(t, "8D4840D6202CC371C32CE0576098")
+	frame[5] ^= 0x01
+	if _, err := New().Decode(frame, time.Now()); err != ErrBadCrc {
+		t.Fatalf("Expected bad crc got %v", err)
+	}
+}
+
+func TestDecodeSurveillanceReplies(t *testing.T) {
+	d := New()
+	now := time.Now()
+	squawkFrame := surveillanceFrame(0x28000AA2, 0x4840D6)   // DF5 squawk 7500
+	altitudeFrame := surveillanceFrame(0x20001838, 0x4840D6) // DF4 38000ft
+
+	if _, err := d.Decode(squawkFrame, now); err != ErrUnknownAddress {
+		t.Fatalf("Expected unknown address before a squitter got %v", err)
+	}
+	if _, err := d.Decode(mustDecodeHex(t, "8D4840D6202CC371C32CE0576098"), now); err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+
+	squawk, err := d.Decode(squawkFrame, now)
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if squawk.Icao != "4840D6" || squawk.Squawk.Valid == false || squawk.Squawk.Value != 7500 {
+		t.Fatalf("Expected 4840D6 squawking 7500 got %s %v", squawk.Icao, squawk.Squawk)
+	}
+	altitude, err := d.Decode(altitudeFrame, now)
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+	if altitude.Altitude.Valid == false || altitude.Altitude.Value != 38000 {
+		t.Fatalf("Expected altitude 38000 got %v", altitude.Altitude)
+	}
+	if altitude.OnGround.Valid == false || altitude.OnGround.Value {
+		t.Fatalf("Expected airborne flight status got %v", altitude.OnGround)
+	}
+
+	if _, err := d.Decode(squawkFrame, now.Add(2*KNOWN_ADDRESS_TTL)); err != ErrUnknownAddress {
+		t.Fatalf("Expected address to expire got %v", err)
+	}
+}
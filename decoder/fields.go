@@ -0,0 +1,204 @@
+package decoder
+
+import "strings"
+
+const INVALID_ALTITUDE = -9999
+
+// getBits reads the bits first through last inclusive, bits are numbered from 1 at the start
+// of the frame the same way as the ICAO docs and https://mode-s.org/decode
+func getBits(data []byte, first int, last int) uint32 {
+	var result uint32
+	for bit := first; bit <= last; bit++ {
+		b := data[(bit-1)/8]
+		set := (b >> (7 - uint((bit-1)%8))) & 1
+		result = result<<1 | uint32(set)
+	}
+	return result
+}
+
+// getMEBits is getBits relative to the 56 bit ME field of an extended squitter
+func getMEBits(data []byte, first int, last int) uint32 {
+	return getBits(data, first+32, last+32)
+}
+
+// decodeID13Field reorders the 13 bit identity (C1 A1 C2 A2 C4 A4 X B1 D1 B2 D2 B4 D4)
+// into 0xABCD where each hex digit is one of the octal squawk digits
+func decodeID13Field(id13 uint32) uint32 {
+	var hexGillham uint32
+	if id13&0x1000 != 0 {
+		hexGillham |= 0x0010 // C1
+	}
+	if id13&0x0800 != 0 {
+		hexGillham |= 0x1000 // A1
+	}
+	if id13&0x0400 != 0 {
+		hexGillham |= 0x0020 // C2
+	}
+	if id13&0x0200 != 0 {
+		hexGillham |= 0x2000 // A2
+	}
+	if id13&0x0100 != 0 {
+		hexGillham |= 0x0040 // C4
+	}
+	if id13&0x0080 != 0 {
+		hexGillham |= 0x4000 // A4
+	}
+	if id13&0x0020 != 0 {
+		hexGillham |= 0x0100 // B1
+	}
+	if id13&0x0010 != 0 {
+		hexGillham |= 0x0001 // D1
+	}
+	if id13&0x0008 != 0 {
+		hexGillham |= 0x0200 // B2
+	}
+	if id13&0x0004 != 0 {
+		hexGillham |= 0x0002 // D2
+	}
+	if id13&0x0002 != 0 {
+		hexGillham |= 0x0400 // B4
+	}
+	if id13&0x0001 != 0 {
+		hexGillham |= 0x0004 // D4
+	}
+	return hexGillham
+}
+
+// squawkFromID13 returns the squawk the way SBS prints it, 7500 for octal 7500
+func squawkFromID13(id13 uint32) int {
+	hexGillham := decodeID13Field(id13)
+	return int((hexGillham>>12)&0x7)*1000 +
+		int((hexGillham>>8)&0x7)*100 +
+		int((hexGillham>>4)&0x7)*10 +
+		int(hexGillham&0x7)
+}
+
+// modeAToModeC converts a gillham coded altitude to 100s of feet, taken from dump1090
+func modeAToModeC(modeA uint32) int {
+	var fiveHundreds uint32
+	var oneHundreds uint32
+
+	// D1 set or no C bits set are illegal
+	if modeA&0xFFFF8889 != 0 || modeA&0x000000F0 == 0 {
+		return INVALID_ALTITUDE
+	}
+	if modeA&0x0010 != 0 {
+		oneHundreds ^= 0x007 // C1
+	}
+	if modeA&0x0020 != 0 {
+		oneHundreds ^= 0x003 // C2
+	}
+	if modeA&0x0040 != 0 {
+		oneHundreds ^= 0x001 // C4
+	}
+	// 7s become 5s and 5s become 7s
+	if oneHundreds&5 == 5 {
+		oneHundreds ^= 2
+	}
+	if oneHundreds > 5 {
+		return INVALID_ALTITUDE
+	}
+
+	if modeA&0x0002 != 0 {
+		fiveHundreds ^= 0x0FF // D2
+	}
+	if modeA&0x0004 != 0 {
+		fiveHundreds ^= 0x07F // D4
+	}
+	if modeA&0x1000 != 0 {
+		fiveHundreds ^= 0x03F // A1
+	}
+	if modeA&0x2000 != 0 {
+		fiveHundreds ^= 0x01F // A2
+	}
+	if modeA&0x4000 != 0 {
+		fiveHundreds ^= 0x00F // A4
+	}
+	if modeA&0x0100 != 0 {
+		fiveHundreds ^= 0x007 // B1
+	}
+	if modeA&0x0200 != 0 {
+		fiveHundreds ^= 0x003 // B2
+	}
+	if modeA&0x0400 != 0 {
+		fiveHundreds ^= 0x001 // B4
+	}
+
+	if fiveHundreds&1 != 0 {
+		oneHundreds = 6 - oneHundreds
+	}
+	return int(fiveHundreds*5+oneHundreds) - 13
+}
+
+// decodeAC13Field is the altitude code of DF0/4/16/20, returns feet
+func decodeAC13Field(ac13 uint32) int {
+	metric := ac13&0x0040 != 0
+	quarterFeet := ac13&0x0010 != 0
+	if metric {
+		return INVALID_ALTITUDE
+	}
+	if quarterFeet {
+		// remove the M and Q bits to get an 11 bit count of 25ft
+		n := ((ac13 & 0x1F80) >> 2) | ((ac13 & 0x0020) >> 1) | (ac13 & 0x000F)
+		return int(n)*25 - 1000
+	}
+	n := modeAToModeC(decodeID13Field(ac13))
+	if n < -12 {
+		return INVALID_ALTITUDE
+	}
+	return n * 100
+}
+
+// decodeAC12Field is the altitude of an airborne position extended squitter, returns feet
+func decodeAC12Field(ac12 uint32) int {
+	quarterFeet := ac12&0x0010 != 0
+	if quarterFeet {
+		n := ((ac12 & 0x0FE0) >> 1) | (ac12 & 0x000F)
+		return int(n)*25 - 1000
+	}
+	// insert M=0 at bit 6 to make it an AC13 field
+	n := modeAToModeC(decodeID13Field(((ac12 & 0x0FC0) << 1) | (ac12 & 0x003F)))
+	if n < -12 {
+		return INVALID_ALTITUDE
+	}
+	return n * 100
+}
+
+// decodeMovement converts the surface position movement field to knots
+func decodeMovement(movement uint32) (float64, bool) {
+	switch {
+	case movement == 0 || movement > 124:
+		return 0, false
+	case movement == 1:
+		return 0, true
+	case movement <= 8:
+		return 0.125 + float64(movement-2)*0.125, true
+	case movement <= 12:
+		return 1 + float64(movement-9)*0.25, true
+	case movement <= 38:
+		return 2 + float64(movement-13)*0.5, true
+	case movement <= 93:
+		return 15 + float64(movement-39), true
+	case movement <= 108:
+		return 70 + float64(movement-94)*2, true
+	case movement <= 123:
+		return 100 + float64(movement-109)*5, true
+	}
+	return 175, true
+}
+
+const CALLSIGN_CHARSET = "#ABCDEFGHIJKLMNOPQRSTUVWXYZ##### ###############0123456789######"
+
+func decodeCallsign(data []byte) string {
+	result := make([]byte, 0, 8)
+	for i := 0; i < 8; i++ {
+		first := 9 + i*6
+		c := CALLSIGN_CHARSET[getMEBits(data, first, first+5)]
+		if c == '#' {
+			continue
+		}
+		result = append(result, c)
+	}
+	// callsigns are padded with spaces to 8 characters
+	return strings.TrimRight(string(result), " ")
+}
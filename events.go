@@ -0,0 +1,189 @@
+package main
+
+import (
+	sql "database/sql"
+	"sort"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+const (
+	EVENT_TAKEOFF      = "takeoff"
+	EVENT_LANDING      = "landing"
+	EVENT_TOUCH_AND_GO = "touch_and_go"
+	EVENT_GO_AROUND    = "go_around"
+
+	// back in the air within this long of touching down is a touch and go
+	TOUCH_AND_GO_MAX_MS = 60_000
+	// kt, ground and air transitions slower than this are the flag flickering while taxiing
+	MOVEMENT_MIN_SPEED = 40
+	// ft, a go around turns back up below this after descending and then climbing at least GO_AROUND_MIN_CHANGE
+	GO_AROUND_MAX_ALT    = 3000
+	GO_AROUND_MIN_CHANGE = 400
+	// ft/min, the climb out of a go around
+	GO_AROUND_MIN_VRATE = 300
+	// a position or altitude this close to an event is where it happened
+	EVENT_NEAREST_MAX_MS = 60_000
+)
+
+// FlightEvent is a movement of the aircraft worked out from the series of one flight
+type FlightEvent struct {
+	Type         string
+	TimestampUTC int64
+	Lat          Nullable[float32]
+	Long         Nullable[float32]
+	Altitude     Nullable[float32]
+	Airport      string // nearest airport ident, set by annotateEvents
+	Runway       string
+}
+
+// latestAt is the last value in a series at or before timestamp
+func latestAt[T int | float32 | bool](series []DataOverTime[T], timestamp int64) Nullable[T] {
+	result := Nullable[T]{}
+	for _, point := range series {
+		if point.TimestampUTC > timestamp {
+			break
+		}
+		result = Nullable[T]{Value: point.Data, Valid: true}
+	}
+	return result
+}
+
+// nearestAltitude is the altitude closest in time to timestamp within EVENT_NEAREST_MAX_MS
+func nearestAltitude(data CollectedData, timestamp int64) Nullable[float32] {
+	result := Nullable[float32]{}
+	best := int64(EVENT_NEAREST_MAX_MS) + 1
+	for _, point := range data.Altitude {
+		if diff := abs(point.TimestampUTC - timestamp); diff < best {
+			best = diff
+			result = Nullable[float32]{Value: point.Data, Valid: true}
+		}
+	}
+	return result
+}
+
+func newFlightEvent(data CollectedData, eventType string, timestamp int64) FlightEvent {
+	event := FlightEvent{Type: eventType, TimestampUTC: timestamp, Altitude: nearestAltitude(data, timestamp)}
+	best := int64(EVENT_NEAREST_MAX_MS) + 1
+	for _, c := range data.Coordinates {
+		if diff := abs(c.TimestampUTC - timestamp); diff < best {
+			best = diff
+			event.Lat = Nullable[float32]{Value: c.Lat, Valid: true}
+			event.Long = Nullable[float32]{Value: c.Long, Valid: true}
+		}
+	}
+	return event
+}
+
+// isMovingFast is false only when the ground speed is known and too slow for a takeoff or landing
+func isMovingFast(data CollectedData, timestamp int64) bool {
+	speed := latestAt(data.GroundSpeed, timestamp)
+	return speed.Valid == false || speed.Value >= MOVEMENT_MIN_SPEED
+}
+
+// touchedDownBetween is whether the aircraft reported being on the ground after from and up to until
+func touchedDownBetween(data CollectedData, from int64, until int64) bool {
+	for _, point := range data.OnGround {
+		if point.Data && point.TimestampUTC > from && point.TimestampUTC <= until {
+			return true
+		}
+	}
+	return false
+}
+
+// climbingBetween is false only when vertical rates were reported and none were a climb
+func climbingBetween(data CollectedData, from int64, until int64) bool {
+	reported := false
+	for _, point := range data.VerticalRate {
+		if point.TimestampUTC < from || point.TimestampUTC > until {
+			continue
+		}
+		if point.Data >= GO_AROUND_MIN_VRATE {
+			return true
+		}
+		reported = true
+	}
+	return reported == false
+}
+
+// groundEvents turns the on ground transitions into takeoffs, landings and touch and goes
+func groundEvents(data CollectedData) []FlightEvent {
+	events := make([]FlightEvent, 0)
+	for i := 1; i < len(data.OnGround); i++ {
+		previous, current := data.OnGround[i-1], data.OnGround[i]
+		if previous.Data == current.Data || isMovingFast(data, current.TimestampUTC) == false {
+			continue
+		}
+		if current.Data {
+			events = append(events, newFlightEvent(data, EVENT_LANDING, current.TimestampUTC))
+			continue
+		}
+		last := len(events) - 1
+		if last >= 0 && events[last].Type == EVENT_LANDING && current.TimestampUTC-events[last].TimestampUTC <= TOUCH_AND_GO_MAX_MS {
+			events[last].Type = EVENT_TOUCH_AND_GO
+			continue
+		}
+		events = append(events, newFlightEvent(data, EVENT_TAKEOFF, current.TimestampUTC))
+	}
+	return events
+}
+
+// goArounds finds approaches that turned back up low without touching down
+func goArounds(data CollectedData) []FlightEvent {
+	events := make([]FlightEvent, 0)
+	if len(data.Altitude) == 0 {
+		return events
+	}
+	high, low := data.Altitude[0], data.Altitude[0]
+	for _, point := range data.Altitude[1:] {
+		if point.Data < low.Data {
+			low = point
+		}
+		if high.Data-low.Data < GO_AROUND_MIN_CHANGE {
+			// not descending yet
+			if point.Data > high.Data {
+				high, low = point, point
+			}
+			continue
+		}
+		if point.Data-low.Data < GO_AROUND_MIN_CHANGE {
+			continue
+		}
+		if low.Data <= GO_AROUND_MAX_ALT &&
+			touchedDownBetween(data, high.TimestampUTC, point.TimestampUTC) == false &&
+			climbingBetween(data, low.TimestampUTC, point.TimestampUTC) {
+			events = append(events, newFlightEvent(data, EVENT_GO_AROUND, low.TimestampUTC))
+		}
+		high, low = point, point
+	}
+	return events
+}
+
+// detectEvents lists every movement in one flight in time order
+func detectEvents(data CollectedData) []FlightEvent {
+	events := append(groundEvents(data), goArounds(data)...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].TimestampUTC < events[j].TimestampUTC
+	})
+	return events
+}
+
+func toNullFloat(value Nullable[float32]) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: float64(value.Value), Valid: value.Valid}
+}
+
+func toDatabaseEvents(events []FlightEvent) []storage.Event {
+	result := make([]storage.Event, 0, len(events))
+	for _, e := range events {
+		result = append(result, storage.Event{
+			Type:         e.Type,
+			TimestampUTC: e.TimestampUTC,
+			Lat:          toNullFloat(e.Lat),
+			Long:         toNullFloat(e.Long),
+			Altitude:     toNullFloat(e.Altitude),
+			Airport:      e.Airport,
+			Runway:       e.Runway,
+		})
+	}
+	return result
+}
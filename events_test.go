@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+	database "github.com/kc8/dump-1090-aggergator/storage/database"
+)
+
+func series[T int | float32 | bool](points ...DataOverTime[T]) []DataOverTime[T] {
+	return points
+}
+
+func TestDetectGroundEvents(t *testing.T) {
+	data := CollectedData{
+		// taxi, take off, touch and go, land, taxi in
+		OnGround: series(
+			DataOverTime[bool]{Data: true, TimestampUTC: 0},
+			DataOverTime[bool]{Data: false, TimestampUTC: 300_000},
+			DataOverTime[bool]{Data: true, TimestampUTC: 900_000},
+			DataOverTime[bool]{Data: false, TimestampUTC: 930_000},
+			DataOverTime[bool]{Data: true, TimestampUTC: 1_500_000},
+		),
+		GroundSpeed: series(
+			DataOverTime[float32]{Data: 15, TimestampUTC: 0},
+			DataOverTime[float32]{Data: 140, TimestampUTC: 290_000},
+			DataOverTime[float32]{Data: 120, TimestampUTC: 890_000},
+		),
+		Coordinates: []CordinatesOverTime{{Lat: 52.3, Long: 4.76, TimestampUTC: 301_000}},
+	}
+	events := detectEvents(data)
+	expected := []string{EVENT_TAKEOFF, EVENT_TOUCH_AND_GO, EVENT_LANDING}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %v got %v", expected, events)
+	}
+	for i, e := range expected {
+		if events[i].Type != e {
+			t.Fatalf("Expected %v got %v", expected, events)
+		}
+	}
+	if events[0].Lat.Valid == false || events[0].Lat.Value != 52.3 {
+		t.Fatalf("Expected the takeoff at 52.3 got %v", events[0].Lat)
+	}
+
+	// flickering while taxiing slowly is not a movement
+	taxi := CollectedData{
+		OnGround:    series(DataOverTime[bool]{Data: true, TimestampUTC: 0}, DataOverTime[bool]{Data: false, TimestampUTC: 1000}),
+		GroundSpeed: series(DataOverTime[float32]{Data: 12, TimestampUTC: 0}),
+	}
+	if events := detectEvents(taxi); len(events) != 0 {
+		t.Fatalf("Expected no events while taxiing got %v", events)
+	}
+}
+
+func TestDetectGoAround(t *testing.T) {
+	data := CollectedData{
+		Altitude: series(
+			DataOverTime[float32]{Data: 4000, TimestampUTC: 0},
+			DataOverTime[float32]{Data: 2500, TimestampUTC: 60_000},
+			DataOverTime[float32]{Data: 600, TimestampUTC: 120_000},
+			DataOverTime[float32]{Data: 1400, TimestampUTC: 150_000},
+			DataOverTime[float32]{Data: 3000, TimestampUTC: 200_000},
+		),
+		VerticalRate: series(DataOverTime[float32]{Data: 1800, TimestampUTC: 140_000}),
+	}
+	events := detectEvents(data)
+	if len(events) != 1 || events[0].Type != EVENT_GO_AROUND || events[0].TimestampUTC != 120_000 || events[0].Altitude.Value != 600 {
+		t.Fatalf("Expected a go around at 600ft got %v", events)
+	}
+	// the same profile with a touch down is a touch and go, not a go around
+	data.OnGround = series(DataOverTime[bool]{Data: false, TimestampUTC: 0}, DataOverTime[bool]{Data: true, TimestampUTC: 125_000})
+	for _, e := range goArounds(data) {
+		t.Fatalf("Expected no go around after touching down got %v", e)
+	}
+}
+
+func TestInsertStoresEvents(t *testing.T) {
+	db, err := database.New("events.db", t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected database got err %s", err.Error())
+	}
+	defer db.Clean()
+	now := int64(1_700_000_000_000)
+	item := storage.MapItem[CollectedData]{Key: "4840D6", Data: CollectedData{
+		Icao:      "4840D6",
+		FirstSeen: now,
+		LastSeen:  now + 600_000,
+		OnGround:  series(DataOverTime[bool]{Data: true, TimestampUTC: now}, DataOverTime[bool]{Data: false, TimestampUTC: now + 300_000}),
+	}}
+	if err := insertEntry(context.Background(), db, item); err != nil {
+		t.Fatalf("Expected insert got err %s", err.Error())
+	}
+	counts, err := db.CountEvents(context.Background(), now, now+3_600_000)
+	if err != nil {
+		t.Fatalf("Expected counts got err %s", err.Error())
+	}
+	if counts[EVENT_TAKEOFF] != 1 || counts[EVENT_LANDING] != 0 {
+		t.Fatalf("Expected 1 takeoff got %v", counts)
+	}
+}
+
+func TestFlightRecordOrdersLateFrames(t *testing.T) {
+	// a lagging receiver delivered the taxi out after the climb out
+	item := storage.MapItem[CollectedData]{Key: "4840D6", Data: CollectedData{
+		Icao:        "4840D6",
+		OnGround:    series(DataOverTime[bool]{Data: false, TimestampUTC: 300_000}, DataOverTime[bool]{Data: true, TimestampUTC: 0}),
+		GroundSpeed: series(DataOverTime[float32]{Data: 140, TimestampUTC: 290_000}, DataOverTime[float32]{Data: 15, TimestampUTC: 0}),
+		Coordinates: []CordinatesOverTime{{Lat: 52.4, Long: 4.9, TimestampUTC: 600_000}, {Lat: 52.3, Long: 4.76, TimestampUTC: 301_000}},
+	}}
+	record := flightRecord(item)
+	if len(record.Events) != 1 || record.Events[0].Type != EVENT_TAKEOFF || record.Events[0].TimestampUTC != 300_000 || record.Events[0].Lat.Float64 != float64(float32(52.3)) {
+		t.Fatalf("Expected a takeoff at 300000 from 52.3 got %v", record.Events)
+	}
+	if len(record.Positions) != 2 || record.Positions[0].TimestampUTC != 301_000 {
+		t.Fatalf("Expected positions in time order got %v", record.Positions)
+	}
+}
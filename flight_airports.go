@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+
+	airports "github.com/kc8/dump-1090-aggergator/airports"
+)
+
+const (
+	// how far from an airport a takeoff, landing or first or last position can be and still be at it
+	AIRPORT_MAX_KM = 5
+	// ft above the airport, a first or last position lower than this is taken as the origin or destination
+	// when no takeoff or landing was seen
+	AIRPORT_MAX_HEIGHT_FT = 2000
+	// degrees between the track and a runway to take off or land on it
+	RUNWAY_MAX_HEADING_DIFF = 20
+)
+
+// nil when -airports is not set, flights are then stored without an origin or destination
+var airportIndex *airports.Index
+
+func loadAirportIndex(airportsPath string, runwaysPath string) {
+	if airportsPath == "" {
+		return
+	}
+	index, err := airports.LoadFiles(airportsPath, runwaysPath)
+	if err != nil {
+		Log(fmt.Sprintf("Could not load airports: %s", err.Error()), FATAL)
+	}
+	Log(fmt.Sprintf("Loaded %d airports from %s", index.Len(), airportsPath), INFO)
+	airportIndex = index
+}
+
+type airportMatch struct {
+	Airport string
+	Runway  string
+}
+
+func nearestAirport(lat float32, long float32) (*airports.Airport, bool) {
+	if airportIndex == nil {
+		return nil, false
+	}
+	airport, _, ok := airportIndex.Nearest(float64(lat), float64(long), AIRPORT_MAX_KM)
+	return airport, ok
+}
+
+// annotateEvents sets the airport and runway of every event that has a position
+func annotateEvents(data CollectedData, events []FlightEvent) {
+	for i := range events {
+		if events[i].Lat.Valid == false || events[i].Type == EVENT_GO_AROUND {
+			continue
+		}
+		airport, ok := nearestAirport(events[i].Lat.Value, events[i].Long.Value)
+		if ok == false {
+			continue
+		}
+		events[i].Airport = airport.Ident
+		if track := latestAt(data.HeadingTrack, events[i].TimestampUTC); track.Valid {
+			if runway, ok := airport.RunwayFor(float64(track.Value), RUNWAY_MAX_HEADING_DIFF); ok {
+				events[i].Runway = runway.Ident
+			}
+		}
+	}
+}
+
+// lowAtAirport is the airport a position is at when the aircraft was on the ground or close above it
+func lowAtAirport(data CollectedData, position CordinatesOverTime) (airportMatch, bool) {
+	airport, ok := nearestAirport(position.Lat, position.Long)
+	if ok == false {
+		return airportMatch{}, false
+	}
+	onGround := latestAt(data.OnGround, position.TimestampUTC)
+	altitude := nearestAltitude(data, position.TimestampUTC)
+	if (onGround.Valid && onGround.Value) || (altitude.Valid && float64(altitude.Value)-airport.ElevationFt <= AIRPORT_MAX_HEIGHT_FT) {
+		return airportMatch{Airport: airport.Ident}, true
+	}
+	return airportMatch{}, false
+}
+
+// flightAirports guesses where a flight came from and went to, from its first takeoff and last landing
+// and otherwise from where it was first and last seen if that was low over an airport
+func flightAirports(data CollectedData, events []FlightEvent) (airportMatch, airportMatch) {
+	var origin, destination airportMatch
+	for _, e := range events {
+		if e.Type == EVENT_TAKEOFF && e.Airport != "" {
+			origin = airportMatch{Airport: e.Airport, Runway: e.Runway}
+			break
+		}
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == EVENT_LANDING && events[i].Airport != "" {
+			destination = airportMatch{Airport: events[i].Airport, Runway: events[i].Runway}
+			break
+		}
+	}
+	if len(data.Coordinates) == 0 {
+		return origin, destination
+	}
+	if origin.Airport == "" {
+		origin, _ = lowAtAirport(data, data.Coordinates[0])
+	}
+	if destination.Airport == "" {
+		destination, _ = lowAtAirport(data, data.Coordinates[len(data.Coordinates)-1])
+	}
+	return origin, destination
+}
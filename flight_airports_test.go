@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	airports "github.com/kc8/dump-1090-aggergator/airports"
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+	database "github.com/kc8/dump-1090-aggergator/storage/database"
+)
+
+const TEST_AIRPORTS_CSV = `"id","ident","type","name","latitude_deg","longitude_deg","elevation_ft","iata_code"
+2513,"EHAM","large_airport","Amsterdam Airport Schiphol",52.308601,4.76389,-11,"AMS"
+2522,"EHLE","medium_airport","Lelystad Airport",52.4603,5.52722,-13,"LEY"
+`
+
+const TEST_RUNWAYS_CSV = `"id","airport_ref","airport_ident","closed","le_ident","le_heading_degT","he_ident","he_heading_degT"
+1,2513,"EHAM",0,"18R",183,"36L",3
+`
+
+func TestFlightAirports(t *testing.T) {
+	index, _ := airports.LoadAirports(strings.NewReader(TEST_AIRPORTS_CSV))
+	index.LoadRunways(strings.NewReader(TEST_RUNWAYS_CSV))
+	airportIndex = index
+	defer func() { airportIndex = nil }()
+
+	// takes off to the north from Schiphol and is last seen low on approach to Lelystad
+	data := CollectedData{
+		OnGround: series(
+			DataOverTime[bool]{Data: true, TimestampUTC: 0},
+			DataOverTime[bool]{Data: false, TimestampUTC: 300_000},
+		),
+		GroundSpeed:  series(DataOverTime[float32]{Data: 140, TimestampUTC: 290_000}),
+		HeadingTrack: series(DataOverTime[int]{Data: 1, TimestampUTC: 290_000}),
+		Altitude: series(
+			DataOverTime[float32]{Data: 0, TimestampUTC: 300_000},
+			DataOverTime[float32]{Data: 1200, TimestampUTC: 1_200_000},
+		),
+		Coordinates: []CordinatesOverTime{
+			{Lat: 52.31, Long: 4.76, TimestampUTC: 300_000},
+			{Lat: 52.47, Long: 5.50, TimestampUTC: 1_200_000},
+		},
+	}
+	events := detectEvents(data)
+	annotateEvents(data, events)
+	if len(events) != 1 || events[0].Airport != "EHAM" || events[0].Runway != "36L" {
+		t.Fatalf("Expected a takeoff from EHAM 36L got %v", events)
+	}
+	origin, destination := flightAirports(data, events)
+	if origin.Airport != "EHAM" || origin.Runway != "36L" || destination.Airport != "EHLE" {
+		t.Fatalf("Expected EHAM 36L to EHLE got %v %v", origin, destination)
+	}
+
+	// still cruising when last seen so there is no destination
+	data.Altitude[1].Data = 9000
+	if _, destination := flightAirports(data, events); destination.Airport != "" {
+		t.Fatalf("Expected no destination at 9000ft got %v", destination)
+	}
+
+	db, err := database.New("airports.db", t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected database got err %s", err.Error())
+	}
+	defer db.Clean()
+	data.Altitude[1].Data = 1200
+	data.Icao = "4840D6"
+	if err := insertEntry(context.Background(), db, storage.MapItem[CollectedData]{Key: "4840D6", Data: data}); err != nil {
+		t.Fatalf("Expected insert got err %s", err.Error())
+	}
+	flights, err := db.FindFlights(context.Background(), database.FlightFilter{Airport: "ehle"})
+	if err != nil || len(flights) != 1 || flights[0].Origin != "EHAM" || flights[0].OriginRunway != "36L" {
+		t.Fatalf("Expected the flight from EHAM 36L for ehle got %v %v", flights, err)
+	}
+	if flights, _ := db.FindFlights(context.Background(), database.FlightFilter{Airport: "EGLL"}); len(flights) != 0 {
+		t.Fatalf("Expected no flights for EGLL got %v", flights)
+	}
+}
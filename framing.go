@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// SBS lines are around 120 bytes and AVR lines under 60, anything near this is a broken feed
+const MAX_LINE_LEN = 4096
+
+// lineFramer splits a text feed on line feeds, a trailing carriage return is dropped and
+// lines longer than MAX_LINE_LEN are skipped whole instead of being cut into pieces
+type lineFramer struct {
+	reader   *bufio.Reader
+	skipping bool
+	skipped  uint64
+}
+
+func newLineReader(r io.Reader) *bufio.Reader {
+	return bufio.NewReaderSize(r, MAX_LINE_LEN)
+}
+
+func newLineFramer(reader *bufio.Reader) *lineFramer {
+	return &lineFramer{reader: reader}
+}
+
+// next returns the next non empty line, it is only valid until the following call
+func (f *lineFramer) next() ([]byte, error) {
+	for {
+		line, readErr := f.reader.ReadSlice(LINE_FEED_ENDING)
+		if readErr == bufio.ErrBufferFull {
+			if f.skipping == false {
+				f.skipped++
+				Log(fmt.Sprintf("Skipping line longer than %d bytes, %d skipped so far", MAX_LINE_LEN, f.skipped), WARN)
+			}
+			f.skipping = true
+			continue
+		}
+		if readErr != nil {
+			// a partial line without its line feed is dropped with the connection
+			return nil, readErr
+		}
+		if f.skipping {
+			// the end of the over-long line
+			f.skipping = false
+			continue
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			continue
+		}
+		return line, nil
+	}
+}
+
+// consumeLines reads newline framed messages until the reader fails
+func consumeLines(reader *bufio.Reader, rcv receiver, itemQueue *modifyStoQueue, parse lineParser) error {
+	framer := newLineFramer(reader)
+	for {
+		line, readErr := framer.next()
+		if readErr != nil {
+			return readErr
+		}
+		handleLine(line, rcv, itemQueue, parse)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLineFramer(t *testing.T) {
+	long := strings.Repeat("X", MAX_LINE_LEN*2)
+	feed := "MSG,1\r\n\r\n" + long + "\nMSG,2\nMSG,3"
+	// one byte per read is the worst case for partial reads
+	framer := newLineFramer(newLineReader(iotest.OneByteReader(strings.NewReader(feed))))
+	for _, expected := range []string{"MSG,1", "MSG,2"} {
+		line, err := framer.next()
+		if err != nil {
+			t.Fatalf("Expected %s got err %s", expected, err.Error())
+		}
+		if string(line) != expected {
+			t.Fatalf("Expected %s got %q", expected, line)
+		}
+	}
+	// MSG,3 never got its line feed
+	if _, err := framer.next(); err != io.EOF {
+		t.Fatalf("Expected EOF got %v", err)
+	}
+	if framer.skipped != 1 {
+		t.Fatalf("Expected 1 over-long line skipped got %d", framer.skipped)
+	}
+}
+
+// a busy airport feed is a few thousand messages a second, this should be well over that
+func BenchmarkFrameAndParseSBS(b *testing.B) {
+	line := "MSG,3,1,1,A1B2C3,1,2024/10/08,12:00:00.000,2024/10/08,12:00:00.000,,35000,,,40.50000,-73.50000,,,0,0,0,0\r\n"
+	feed := []byte(strings.Repeat(line, 10_000))
+	b.SetBytes(int64(len(feed)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		framer := newLineFramer(newLineReader(bytes.NewReader(feed)))
+		for {
+			msg, err := framer.next()
+			if err != nil {
+				break
+			}
+			if _, err := ParseCSVFormat(msg); err != nil {
+				b.Fatalf("Expected message got err %s", err.Error())
+			}
+		}
+	}
+	b.ReportMetric(float64(b.N*10_000)/b.Elapsed().Seconds(), "msgs/s")
+}
@@ -0,0 +1,29 @@
+package geo
+
+import (
+	"math"
+)
+
+const EARTH_RADIUS_KM = 6371.0
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// DistanceKm is the great circle distance between two points
+func DistanceKm(lat1 float64, lon1 float64, lat2 float64, lon2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLon := radians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * EARTH_RADIUS_KM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// HeadingDiff is the smallest angle between two headings in degrees, 0 to 180
+func HeadingDiff(a float64, b float64) float64 {
+	diff := math.Mod(math.Abs(a-b), 360)
+	if diff > 180 {
+		return 360 - diff
+	}
+	return diff
+}
@@ -0,0 +1,26 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	// Schiphol to Heathrow is about 371km
+	d := DistanceKm(52.3086, 4.7639, 51.4706, -0.4619)
+	if math.Abs(d-371) > 3 {
+		t.Fatalf("Expected about 371km got %f", d)
+	}
+	if DistanceKm(52, 4, 52, 4) != 0 {
+		t.Fatalf("Expected 0 for the same point")
+	}
+}
+
+func TestHeadingDiff(t *testing.T) {
+	cases := [][3]float64{{10, 350, 20}, {90, 270, 180}, {180, 170, 10}, {0, 720, 0}}
+	for _, c := range cases {
+		if d := HeadingDiff(c[0], c[1]); math.Abs(d-c[2]) > 1e-9 {
+			t.Fatalf("Expected %f between %f and %f got %f", c[2], c[0], c[1], d)
+		}
+	}
+}
@@ -3,6 +3,7 @@ module github.com/kc8/dump-1090-aggergator
 go 1.22.2
 
 require (
-	github.com/kc8/kc_go_queue v0.0.0-20241008232854-6b3b9ca0e659 // indirect
-	github.com/mattn/go-sqlite3 v1.14.23 // indirect
+	github.com/kc8/kc_go_queue v0.0.0-20241008232854-6b3b9ca0e659
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.23
 )
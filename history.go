@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+	database "github.com/kc8/dump-1090-aggergator/storage/database"
+)
+
+// callsignSummary lists each callsign a flight used once, in the order they were used
+func callsignSummary(stored []byte) string {
+	var history []DataOverTime[string]
+	if err := json.Unmarshal(stored, &history); err != nil {
+		return ""
+	}
+	callsigns := make([]string, 0, len(history))
+	for _, c := range history {
+		if len(callsigns) == 0 || callsigns[len(callsigns)-1] != c.Data {
+			callsigns = append(callsigns, c.Data)
+		}
+	}
+	return strings.Join(callsigns, " > ")
+}
+
+// phaseSummary lists the ground and air transitions of a flight Example: ground 12:00 > air 12:09
+func phaseSummary(stored []byte) string {
+	var history []DataOverTime[bool]
+	if err := json.Unmarshal(stored, &history); err != nil {
+		return ""
+	}
+	phases := make([]string, 0, len(history))
+	for _, p := range history {
+		phase := "air"
+		if p.Data {
+			phase = "ground"
+		}
+		phases = append(phases, fmt.Sprintf("%s %s", phase, time.UnixMilli(p.TimestampUTC).UTC().Format("15:04")))
+	}
+	return strings.Join(phases, " > ")
+}
+
+// airportSummary is the airport and runway when known Example: EHAM 18R
+func airportSummary(airport string, runway string) string {
+	if airport == "" {
+		return "-"
+	}
+	return strings.TrimSpace(airport + " " + runway)
+}
+
+func printFlights(flights []storage.FlightRecord) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ICAO\tTAIL\tFIRST SEEN\tLAST SEEN\tMSGS\tFROM\tTO\tCALLSIGNS\tPHASES")
+	for _, f := range flights {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\n",
+			f.Icao,
+			f.TailNumber,
+			time.UnixMilli(f.FirstSeen).UTC().Format(time.RFC3339),
+			time.UnixMilli(f.LastSeen).UTC().Format(time.RFC3339),
+			f.MsgCount,
+			airportSummary(f.Origin, f.OriginRunway),
+			airportSummary(f.Destination, f.DestinationRunway),
+			callsignSummary(f.Callsign),
+			phaseSummary(f.OnGround))
+	}
+	w.Flush()
+}
+
+// requireSqlite stops a reporting command pointed at postgres, the queries are only written for sqlite
+func requireSqlite(command string, kind string) {
+	if kind == STORAGE_SQLITE {
+		return
+	}
+	Log(fmt.Sprintf("%s only reads the sqlite database at -dbLoc, -storage=%s is not supported", command, kind), FATAL)
+}
+
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	var (
+		callsign = fs.String("callsign", "", "Flight number to search for, ignores case and * matches anything Example: KLM1023 or KLM*")
+		airport  = fs.String("airport", "", "Only flights guessed to have come from or gone to this airport ident Example: EHAM")
+	)
+	dbLocation, dbFileName := addDatabaseFlags(fs)
+	storageKind := fs.String("storage", STORAGE_SQLITE, "Only sqlite is supported, flights written to postgres are read with SQL")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s history [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	requireSqlite(fs.Name(), *storageKind)
+	if *callsign == "" && *airport == "" {
+		fs.Usage()
+		os.Exit(-1)
+	}
+	dbInstance := openDatabase(*dbFileName, *dbLocation)
+	defer dbInstance.Clean()
+
+	flights, err := dbInstance.FindFlights(context.Background(), database.FlightFilter{Callsign: *callsign, Airport: *airport})
+	if err != nil {
+		Log(fmt.Sprintf("Failed to search for %s %s due to %s", *callsign, *airport, err.Error()), ERROR)
+		return
+	}
+	printFlights(flights)
+}
+
+func runMovements(args []string) {
+	fs := flag.NewFlagSet("movements", flag.ExitOnError)
+	var (
+		since = fs.Duration("since", 24*time.Hour, "Count movements from this long ago until now")
+	)
+	dbLocation, dbFileName := addDatabaseFlags(fs)
+	storageKind := fs.String("storage", STORAGE_SQLITE, "Only sqlite is supported, flights written to postgres are read with SQL")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s movements [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	requireSqlite(fs.Name(), *storageKind)
+	dbInstance := openDatabase(*dbFileName, *dbLocation)
+	defer dbInstance.Clean()
+
+	now := time.Now().UTC()
+	counts, err := dbInstance.CountEvents(context.Background(), now.Add(-*since).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		Log(fmt.Sprintf("Failed to count movements due to %s", err.Error()), ERROR)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, eventType := range []string{EVENT_TAKEOFF, EVENT_LANDING, EVENT_TOUCH_AND_GO, EVENT_GO_AROUND} {
+		fmt.Fprintf(w, "%s\t%d\n", eventType, counts[eventType])
+	}
+	w.Flush()
+}
+
+func runNear(args []string) {
+	fs := flag.NewFlagSet("near", flag.ExitOnError)
+	var (
+		at     = fs.String("at", "", "lat,long of the point to search around Example: 52.31,4.76")
+		radius = fs.Float64("radius", 5, "Km around -at")
+		from   = fs.String("from", "", "RFC3339 time to search from, defaults to -since before -until Example: 2024-10-08T12:00:00Z")
+		until  = fs.String("until", "", "RFC3339 time to search until, defaults to now")
+		since  = fs.Duration("since", 24*time.Hour, "How far back from -until to search when -from is not set")
+	)
+	dbLocation, dbFileName := addDatabaseFlags(fs)
+	storageKind := fs.String("storage", STORAGE_SQLITE, "Only sqlite is supported, flights written to postgres are read with SQL")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s near [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	requireSqlite(fs.Name(), *storageKind)
+	point, pointErr := parseLatLong(*at)
+	if pointErr != nil {
+		fs.Usage()
+		os.Exit(-1)
+	}
+	untilTime := time.Now().UTC()
+	if *until != "" {
+		parsed, err := time.Parse(time.RFC3339, *until)
+		if err != nil {
+			Log(fmt.Sprintf("Invalid -until %s: %s", *until, err.Error()), FATAL)
+		}
+		untilTime = parsed
+	}
+	fromTime := untilTime.Add(-*since)
+	if *from != "" {
+		parsed, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			Log(fmt.Sprintf("Invalid -from %s: %s", *from, err.Error()), FATAL)
+		}
+		fromTime = parsed
+	}
+	dbInstance := openDatabase(*dbFileName, *dbLocation)
+	defer dbInstance.Clean()
+
+	flights, err := dbInstance.FindNear(context.Background(), point.Lat, point.Long, *radius, fromTime.UnixMilli(), untilTime.UnixMilli())
+	if err != nil {
+		Log(fmt.Sprintf("Failed to search near %s due to %s", *at, err.Error()), ERROR)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ICAO\tCLOSEST AT\tDISTANCE KM\tALTITUDE\tLAT\tLONG")
+	for _, f := range flights {
+		altitude := "-"
+		if f.Altitude.Valid {
+			altitude = fmt.Sprintf("%.0f", f.Altitude.Float64)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%.5f\t%.5f\n",
+			f.Icao,
+			time.UnixMilli(f.TimestampUTC).UTC().Format(time.RFC3339),
+			f.DistanceKm,
+			altitude,
+			f.Lat,
+			f.Long)
+	}
+	w.Flush()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	database "github.com/kc8/dump-1090-aggergator/storage/database"
+)
+
+func TestFindByCallsign(t *testing.T) {
+	db, err := database.New("history.db", t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected database got err %s", err.Error())
+	}
+	defer db.Clean()
+
+	klm := createNewDataEntry(&FormattedAdbsMsg{AircraftICAOAddr: "4840D6", CallsignFlightNum: "KLM1023"}, 1000)
+	for i, callsign := range []string{"KLM1023", "KLM1024"} {
+		klm = updateEntry(klm, &FormattedAdbsMsg{AircraftICAOAddr: "4840D6", CallsignFlightNum: callsign}, int64(2000+i))
+	}
+	if len(klm.Data.Callsign) != 2 {
+		t.Fatalf("Expected 2 callsign changes got %v", klm.Data.Callsign)
+	}
+	delta := createNewDataEntry(&FormattedAdbsMsg{AircraftICAOAddr: "A1B2C3"}, 1000)
+	delta = updateEntry(delta, &FormattedAdbsMsg{AircraftICAOAddr: "A1B2C3", CallsignFlightNum: "DAL1"}, 2000)
+	if err := insertEntry(context.Background(), db, klm); err != nil {
+		t.Fatalf("Expected insert got err %s", err.Error())
+	}
+	if err := insertEntry(context.Background(), db, delta); err != nil {
+		t.Fatalf("Expected insert got err %s", err.Error())
+	}
+
+	flights, err := db.FindFlights(context.Background(), database.FlightFilter{Callsign: "klm1024"})
+	if err != nil {
+		t.Fatalf("Expected search got err %s", err.Error())
+	}
+	if len(flights) != 1 || flights[0].Icao != "4840D6" {
+		t.Fatalf("Expected 4840D6 for klm1024 got %v", flights)
+	}
+	if summary := callsignSummary(flights[0].Callsign); summary != "KLM1023 > KLM1024" {
+		t.Fatalf("Expected KLM1023 > KLM1024 got %s", summary)
+	}
+	flights, _ = db.FindFlights(context.Background(), database.FlightFilter{Callsign: "*1*"})
+	if len(flights) != 2 {
+		t.Fatalf("Expected both flights for *1* got %v", flights)
+	}
+	flights, _ = db.FindFlights(context.Background(), database.FlightFilter{Callsign: "DAL_"})
+	if len(flights) != 0 {
+		t.Fatalf("Expected _ to not be a wildcard got %v", flights)
+	}
+}
+
+func TestUpdateEntryTracksFlagTransitions(t *testing.T) {
+	set := Nullable[int]{Value: -1, Valid: true}
+	unset := Nullable[int]{Value: 0, Valid: true}
+	item := createNewDataEntry(&FormattedAdbsMsg{AircraftICAOAddr: "4840D6"}, 0)
+	// taxiing, taking off and then a squawk change with ident
+	item = updateEntry(item, &FormattedAdbsMsg{IsOnGround: set, SquawkChange: unset}, 1_000)
+	item = updateEntry(item, &FormattedAdbsMsg{IsOnGround: set}, 60_000)
+	item = updateEntry(item, &FormattedAdbsMsg{IsOnGround: unset}, 540_000)
+	item = updateEntry(item, &FormattedAdbsMsg{IsOnGround: unset, SquawkChange: set, TransponderIdent: set}, 600_000)
+
+	if len(item.Data.OnGround) != 2 || item.Data.OnGround[0].Data == false || item.Data.OnGround[1].TimestampUTC != 540_000 {
+		t.Fatalf("Expected ground then air at 540000 got %v", item.Data.OnGround)
+	}
+	if len(item.Data.SquawkChange) != 2 || len(item.Data.Ident) != 1 {
+		t.Fatalf("Expected squawk change and ident transitions got %v %v", item.Data.SquawkChange, item.Data.Ident)
+	}
+	if summary := phaseSummary(traveseTheData[bool](item.Data.OnGround)); summary != "ground 00:00 > air 00:09" {
+		t.Fatalf("Expected ground 00:00 > air 00:09 got %s", summary)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+func TestImportSBSFiles(t *testing.T) {
+	defer func(source string, tz *time.Location) { timeSource, receiverTimezone = source, tz }(timeSource, receiverTimezone)
+	timeSource, receiverTimezone = TIME_SOURCE_GENERATED, time.UTC
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "first.sbs")
+	zipped := filepath.Join(dir, "second.sbs.gz")
+	lines := "MSG,3,1,1,A1B2C3,1,2024/10/08,12:00:00.000,2024/10/08,12:00:00.000,,35000,,,40.50000,-73.50000,,,0,0,0,0\n" +
+		"AIR,,1,1,A1B2C3,1,2024/10/08,12:00:00.500,2024/10/08,12:00:00.500\n" +
+		"MSG,3,1,1,A1B2C3,1,2024/10/08,12:00:01.000,2024/10/08,12:00:01.000,,35100,,,40.60000,-73.60000,,,0,0,0,0\n"
+	if err := os.WriteFile(plain, []byte(lines), 0644); err != nil {
+		t.Fatalf("Failed to write test file %s", err.Error())
+	}
+	f, _ := os.Create(zipped)
+	gz := gzip.NewWriter(f)
+	// an hour later the same aircraft is back, the scan between should have written the first visit
+	gz.Write([]byte("MSG,3,1,1,A1B2C3,1,2024/10/08,13:30:00.000,2024/10/08,13:30:00.000,,5000,,,40.70000,-73.70000,,,0,0,0,0\n" +
+		"MSG,3,1,1,A1B2C3,1,2024/10/08,13:30:05.000,2024/10/08,13:30:05.000,,4900,,,40.80000,-73.80000,,,0,0,0,0\n"))
+	gz.Close()
+	f.Close()
+
+	inserted := make([]storage.MapItem[CollectedData], 0)
+	importer := newSbsImporter(3_600_000, func(item storage.MapItem[CollectedData]) error {
+		inserted = append(inserted, item)
+		return nil
+	})
+	for _, path := range []string{plain, zipped} {
+		if _, err := importer.importFile(path); err != nil {
+			t.Fatalf("Expected %s to import got err %s", path, err.Error())
+		}
+	}
+	importer.flush(0, true)
+
+	if len(inserted) != 2 {
+		t.Fatalf("Expected 2 visits to be written got %d", len(inserted))
+	}
+	first := inserted[0].Data
+	if first.FirstSeen != 1728388800000 || first.LastSeen != 1728388801000 {
+		t.Fatalf("Expected first visit to use message timestamps got %d - %d", first.FirstSeen, first.LastSeen)
+	}
+	// the same messages through the live queue have to make the same flight, every series included
+	store := storage.NewMapStorage[CollectedData]()
+	q := NewQueue(&store)
+	go q.run(make(chan Nullable[storage.MapItem[CollectedData]]))
+	defer q.stop()
+	for _, line := range strings.Split(strings.TrimSpace(lines), "\n") {
+		msg, err := ParseCSVFormat([]byte(line))
+		if errors.Is(err, errFrameSkipped) {
+			continue
+		}
+		q.updateOrAdd(msg)
+	}
+	q.sync()
+	live, err := store.Search("A1B2C3", simpleKeyCompare)
+	if err != nil {
+		t.Fatalf("Expected A1B2C3 in live storage got err %s", err.Error())
+	}
+	if imported, collected := flightRecord(inserted[0]), flightRecord(live); reflect.DeepEqual(imported, collected) == false {
+		t.Fatalf("Expected the imported flight to match the live one got altitude %s want %s", imported.Altitude, collected.Altitude)
+	}
+	second := inserted[1].Data
+	if second.FirstSeen != 1728394200000 || second.LastSeen != 1728394205000 {
+		t.Fatalf("Expected second visit to use message timestamps got %d - %d", second.FirstSeen, second.LastSeen)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	decoder "github.com/kc8/dump-1090-aggergator/decoder"
+)
+
+const (
+	// a feeder can name itself by sending this line before any data, otherwise it is known by its address
+	LISTEN_HANDSHAKE           = "RECEIVER "
+	LISTEN_MAX_RECEIVER_ID_LEN = 64
+)
+
+// listener accepts feeders pushing to us, the push side of a receiver
+type listener struct {
+	Addr   string
+	Format string
+}
+
+func (l listener) String() string {
+	return fmt.Sprintf("%s %s", l.Addr, l.Format)
+}
+
+// listenerList is a repeatable -listen flag
+type listenerList []listener
+
+func (l *listenerList) String() string {
+	specs := make([]string, 0, len(*l))
+	for _, r := range *l {
+		specs = append(specs, fmt.Sprintf("%s/%s", r.Addr, r.Format))
+	}
+	return strings.Join(specs, ",")
+}
+
+func (l *listenerList) Set(spec string) error {
+	r, err := parseListener(spec)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, r)
+	return nil
+}
+
+// parseListener reads [host]:port[/format], json is not pushed so only sbs, avr and beast are accepted
+func parseListener(spec string) (listener, error) {
+	result := listener{Addr: spec, Format: "sbs"}
+	if idx := strings.LastIndex(spec, "/"); idx >= 0 {
+		result.Addr = spec[:idx]
+		result.Format = spec[idx+1:]
+	}
+	if result.Format != "sbs" && result.Format != "avr" && result.Format != "beast" {
+		return listener{}, errors.New(fmt.Sprintf("Unknown feed format %s in listener %s expected sbs, avr or beast", result.Format, spec))
+	}
+	if _, _, splitErr := net.SplitHostPort(result.Addr); splitErr != nil {
+		return listener{}, errors.New(fmt.Sprintf("Listener %s needs a port: %s", spec, splitErr.Error()))
+	}
+	return result, nil
+}
+
+// startListener accepts feeders on l until ctx is done, the bound address is returned so port 0 can be used
+func startListener(ctx context.Context, l listener, itemQueue *modifyStoQueue) (net.Addr, error) {
+	ln, listenErr := net.Listen("tcp", l.Addr)
+	if listenErr != nil {
+		return nil, listenErr
+	}
+	Log(fmt.Sprintf("Listening for %s feeders on %s", l.Format, ln.Addr()), INFO)
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	go func() {
+		for {
+			conn, acceptErr := ln.Accept()
+			if acceptErr != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				Log(fmt.Sprintf("Failed to accept feeder on %s due to %s", ln.Addr(), acceptErr.Error()), WARN)
+				continue
+			}
+			go serveFeeder(ctx, conn, l.Format, itemQueue)
+		}
+	}()
+	return ln.Addr(), nil
+}
+
+// readHandshake returns the id a feeder sent, or "" when it went straight to data
+func readHandshake(reader *bufio.Reader) (string, error) {
+	start, peekErr := reader.Peek(len(LISTEN_HANDSHAKE))
+	if peekErr != nil && len(start) == 0 {
+		return "", peekErr
+	}
+	if string(start) != LISTEN_HANDSHAKE {
+		return "", nil
+	}
+	line, readErr := reader.ReadString('\n')
+	if readErr != nil {
+		return "", readErr
+	}
+	id := strings.TrimSpace(strings.TrimPrefix(line, LISTEN_HANDSHAKE))
+	if id == "" || len(id) > LISTEN_MAX_RECEIVER_ID_LEN || strings.ContainsAny(id, " \t") {
+		return "", errors.New(fmt.Sprintf("Invalid receiver id %q in handshake", id))
+	}
+	return id, nil
+}
+
+func serveFeeder(ctx context.Context, conn net.Conn, format string, itemQueue *modifyStoQueue) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	host, port, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	rcv := receiver{Id: host, Host: host, Port: port, Format: format}
+	reader := newLineReader(idleConn{Conn: conn, timeout: readIdleTimeout})
+	id, handshakeErr := readHandshake(reader)
+	if handshakeErr != nil {
+		Log(fmt.Sprintf("Dropping feeder %s due to %s", conn.RemoteAddr(), handshakeErr.Error()), WARN)
+		return
+	}
+	if id != "" {
+		rcv.Id = id
+	}
+	Log(fmt.Sprintf("Feeder connected %s", rcv), INFO)
+
+	var readErr error
+	switch format {
+	case "beast":
+		readErr = consumeBeast(reader, rcv, decoder.New(), itemQueue)
+	case "avr":
+		readErr = consumeLines(reader, rcv, itemQueue, newAVRParser(decoder.New()))
+	default:
+		readErr = consumeLines(reader, rcv, itemQueue, ParseCSVFormat)
+	}
+	Log(fmt.Sprintf("Feeder disconnected %s: %s", rcv, readErr.Error()), INFO)
+}
+
+// consumeBeast reads beast frames until the reader fails
+func consumeBeast(reader *bufio.Reader, rcv receiver, modeS *decoder.Decoder, itemQueue *modifyStoQueue) error {
+	for {
+		frame, readErr := readBeastFrame(reader)
+		if errors.Is(readErr, errBeastResync) {
+			continue
+		}
+		if readErr != nil {
+			return readErr
+		}
+		handleBeastFrame(frame, rcv, modeS, itemQueue)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+)
+
+func waitForMessage(t *testing.T, q *modifyStoQueue) *FormattedAdbsMsg {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		task := q.queue.Deque()
+		if task.taskType == UPDATE_OR_ADD {
+			return task.raw
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected a message to be queued")
+	return nil
+}
+
+func TestParseListener(t *testing.T) {
+	l, err := parseListener(":30104/beast")
+	if err != nil || l.Addr != ":30104" || l.Format != "beast" {
+		t.Fatalf("Expected :30104 beast got %v %v", l, err)
+	}
+	if _, err := parseListener(":30104/json"); err == nil {
+		t.Fatalf("Expected json to be refused")
+	}
+	if _, err := parseListener("localhost/sbs"); err == nil {
+		t.Fatalf("Expected a listener without a port to fail")
+	}
+}
+
+func TestListenerAcceptsSBSWithHandshake(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := NewQueue(nil)
+	addr, err := startListener(ctx, listener{Addr: "127.0.0.1:0", Format: "sbs"}, q)
+	if err != nil {
+		t.Fatalf("Expected to listen got err %s", err.Error())
+	}
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("Expected to connect got err %s", err.Error())
+	}
+	defer conn.Close()
+	conn.Write([]byte("RECEIVER north\r\n"))
+	conn.Write([]byte("MSG,3,1,1,A1B2C3,1,2024/10/08,12:00:00.000,2024/10/08,12:00:00.000,,35000,,,40.50000,-73.50000,,,0,0,0,0\r\n"))
+
+	msg := waitForMessage(t, q)
+	if msg.AircraftICAOAddr != "A1B2C3" || msg.ReceiverId != "north" {
+		t.Fatalf("Expected A1B2C3 from north got %s from %s", msg.AircraftICAOAddr, msg.ReceiverId)
+	}
+}
+
+func TestListenerAcceptsBeastBySourceAddress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := NewQueue(nil)
+	addr, err := startListener(ctx, listener{Addr: "127.0.0.1:0", Format: "beast"}, q)
+	if err != nil {
+		t.Fatalf("Expected to listen got err %s", err.Error())
+	}
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("Expected to connect got err %s", err.Error())
+	}
+	defer conn.Close()
+	frame, _ := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	raw := []byte{0x1a, BEAST_TYPE_MODE_S_LONG, 0, 0, 0, 0, 0, 1, 0x80}
+	conn.Write(append(raw, frame...))
+
+	msg := waitForMessage(t, q)
+	if msg.CallsignFlightNum != "KLM1023" || msg.ReceiverId != "127.0.0.1" {
+		t.Fatalf("Expected KLM1023 from 127.0.0.1 got %s from %s", msg.CallsignFlightNum, msg.ReceiverId)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	database "github.com/kc8/dump-1090-aggergator/storage/database"
+)
+
+func printMigrations(statuses []database.MigrationStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tAPPLIED\tDESCRIPTION")
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != 0 {
+			applied = time.UnixMilli(s.AppliedAt).UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, applied, s.Description)
+	}
+	w.Flush()
+}
+
+// runMigrate shows or applies schema migrations, the collector applies them itself on start so
+// this is for looking before upgrading or stepping a copy of a database through them
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbLocation, dbFileName := addDatabaseFlags(fs)
+	to := fs.Int("to", database.LatestSchemaVersion(), "With up, stop after this schema version")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s migrate [flags] status|up\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 || (fs.Arg(0) != "status" && fs.Arg(0) != "up") {
+		fs.Usage()
+		os.Exit(-1)
+	}
+	dbInstance, openErr := database.Open(*dbFileName, *dbLocation)
+	if openErr != nil {
+		Log(fmt.Sprintf("Could not open database: %s", openErr.Error()), FATAL)
+	}
+	defer dbInstance.Clean()
+	ctx := context.Background()
+
+	if fs.Arg(0) == "up" {
+		applied, err := dbInstance.MigrateTo(ctx, *to)
+		for _, m := range applied {
+			Log(fmt.Sprintf("Applied migration %d %s", m.Version, m.Description), INFO)
+		}
+		if err != nil {
+			Log(fmt.Sprintf("Failed to migrate: %s", err.Error()), ERROR)
+			return
+		}
+	}
+	statuses, err := dbInstance.Migrations(ctx)
+	if err != nil {
+		Log(fmt.Sprintf("Failed to read migrations: %s", err.Error()), ERROR)
+		return
+	}
+	printMigrations(statuses)
+}
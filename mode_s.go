@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	decoder "github.com/kc8/dump-1090-aggergator/decoder"
+)
+
+var errFrameSkipped = errors.New("Frame does not carry anything to decode")
+
+// isSkippableModeSErr is true for frames that are normal to see on a busy feed and not worth logging
+func isSkippableModeSErr(err error) bool {
+	return errors.Is(err, decoder.ErrBadCrc) ||
+		errors.Is(err, decoder.ErrUnknownAddress) ||
+		errors.Is(err, decoder.ErrUnsupported)
+}
+
+// sbsTransmissionType maps a decoded frame onto the MSG transmission type dump1090 would have used for it
+func sbsTransmissionType(msg *decoder.Message) string {
+	switch msg.DownlinkFormat {
+	case 4, 20:
+		return "5"
+	case 5, 21:
+		return "6"
+	case 11:
+		return "8"
+	}
+	switch {
+	case msg.TypeCode >= 1 && msg.TypeCode <= 4:
+		return "1"
+	case msg.TypeCode >= 5 && msg.TypeCode <= 8:
+		return "2"
+	case msg.TypeCode >= 9 && msg.TypeCode <= 18, msg.TypeCode >= 20 && msg.TypeCode <= 22:
+		return "3"
+	case msg.TypeCode == 19:
+		return "4"
+	case msg.TypeCode == 28:
+		return "6"
+	}
+	return "8"
+}
+
+// sbsFlag follows SBS where a set flag is -1
+func sbsFlag(flag decoder.Nullable[bool]) Nullable[int] {
+	if flag.Valid == false {
+		return Nullable[int]{Valid: false}
+	}
+	if flag.Value {
+		return Nullable[int]{Value: -1, Valid: true}
+	}
+	return Nullable[int]{Value: 0, Valid: true}
+}
+
+func toFloat32[T int | float64](value decoder.Nullable[T]) Nullable[float32] {
+	return Nullable[float32]{Value: float32(value.Value), Valid: value.Valid}
+}
+
+func formattedFromModeS(msg *decoder.Message, received time.Time) *FormattedAdbsMsg {
+	result := FormattedAdbsMsg{
+		MessageType:      "MSG",
+		TransmissionType: sbsTransmissionType(msg),
+		AircraftICAOAddr: msg.Icao,
+
+		GeneratedTimestamp: received,
+		LoggedTimestamp:    received,
+
+		CallsignFlightNum: msg.Callsign,
+		Altitude:          toFloat32(msg.Altitude),
+		GroundSpeed:       toFloat32(msg.GroundSpeed),
+		HeadingTrack:      Nullable[int]{Value: int(msg.Track.Value), Valid: msg.Track.Valid},
+		VerticalRate:      toFloat32(msg.VerticalRate),
+		SquawkCode:        Nullable[int]{Value: msg.Squawk.Value, Valid: msg.Squawk.Valid},
+		SquawkChange:      sbsFlag(msg.Alert),
+		Emergency:         sbsFlag(msg.Emergency),
+		TransponderIdent:  sbsFlag(msg.SPI),
+		IsOnGround:        sbsFlag(msg.OnGround),
+		CPR:               Nullable[decoder.CPRFrame]{Value: msg.CPR.Value, Valid: msg.CPR.Valid},
+	}
+	return &result
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+
+	geo "github.com/kc8/dump-1090-aggergator/geo"
+)
+
+const (
+	KM_PER_NM = 1.852
+	// allowed on top of the implied speed and climb, covers position and Mode C rounding between close messages
+	PLAUSIBLE_POSITION_SLACK_KM = 1
+	PLAUSIBLE_ALTITUDE_SLACK_FT = 300
+	// after this many rejections in a row the last stored value is taken to be the bad one and the track moves on
+	PLAUSIBLE_MAX_REJECTS_IN_ROW = 5
+)
+
+// plausibilityRules drop positions and altitudes an aircraft could not have reached from its last stored one
+type plausibilityRules struct {
+	MaxSpeedKt    float64 // 0 turns the speed check off
+	MaxRangeKm    float64 // from -receiverLocation, 0 turns the range check off
+	MaxClimbFtMin float64 // 0 turns the altitude jump check off
+	LogRejected   bool
+}
+
+var plausibility = plausibilityRules{MaxSpeedKt: 1000, MaxRangeKm: 600, MaxClimbFtMin: 15_000}
+
+func addPlausibilityFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&plausibility.MaxSpeedKt, "maxSpeed", plausibility.MaxSpeedKt, "Knots, drop positions that imply flying faster than this since the last one, 0 turns it off")
+	fs.Float64Var(&plausibility.MaxRangeKm, "maxRange", plausibility.MaxRangeKm, "Km, drop positions further than this from -receiverLocation, 0 turns it off")
+	fs.Float64Var(&plausibility.MaxClimbFtMin, "maxClimbRate", plausibility.MaxClimbFtMin, "Ft/min, drop altitudes that imply climbing or descending faster than this since the last one, 0 turns it off")
+	fs.BoolVar(&plausibility.LogRejected, "logRejected", plausibility.LogRejected, "Log every position and altitude that is dropped as implausible")
+}
+
+// elapsedSeconds is at least a second so messages with the same timestamp are not held to a zero distance
+func elapsedSeconds(from int64, until int64) float64 {
+	return math.Max(1, math.Abs(float64(until-from))/1000)
+}
+
+func (r plausibilityRules) reject(data *CollectedData, what string, reason string) {
+	data.RejectedPoints++
+	if r.LogRejected {
+		Log(fmt.Sprintf("Dropped %s of %s, %s", what, data.Icao, reason), INFO)
+	}
+}
+
+// positionReason is why a position is implausible, or "" when it can be stored
+func (r plausibilityRules) positionReason(data *CollectedData, lat float32, long float32, timestamp int64) string {
+	if r.MaxRangeKm > 0 && receiverLocation.Valid {
+		d := geo.DistanceKm(receiverLocation.Value.Lat, receiverLocation.Value.Long, float64(lat), float64(long))
+		if d > r.MaxRangeKm {
+			return fmt.Sprintf("%.0fkm from the receiver", d)
+		}
+	}
+	if r.MaxSpeedKt > 0 && len(data.Coordinates) > 0 && data.PositionRejectRun < PLAUSIBLE_MAX_REJECTS_IN_ROW {
+		last := data.Coordinates[len(data.Coordinates)-1]
+		d := geo.DistanceKm(float64(last.Lat), float64(last.Long), float64(lat), float64(long))
+		seconds := elapsedSeconds(last.TimestampUTC, timestamp)
+		if d > r.MaxSpeedKt*KM_PER_NM*seconds/3600+PLAUSIBLE_POSITION_SLACK_KM {
+			return fmt.Sprintf("%.1fkm in %.0fs", d, seconds)
+		}
+	}
+	return ""
+}
+
+// acceptPosition counts and drops implausible positions, true when lat long should be stored
+func (r plausibilityRules) acceptPosition(data *CollectedData, lat float32, long float32, timestamp int64) bool {
+	reason := r.positionReason(data, lat, long, timestamp)
+	if reason == "" {
+		data.PositionRejectRun = 0
+		return true
+	}
+	data.PositionRejectRun++
+	r.reject(data, fmt.Sprintf("position %f,%f", lat, long), reason)
+	return false
+}
+
+// acceptAltitude counts and drops altitudes that jumped too far from the last stored one
+func (r plausibilityRules) acceptAltitude(data *CollectedData, altitude float32, timestamp int64) bool {
+	if r.MaxClimbFtMin <= 0 || len(data.Altitude) == 0 || data.AltitudeRejectRun >= PLAUSIBLE_MAX_REJECTS_IN_ROW {
+		data.AltitudeRejectRun = 0
+		return true
+	}
+	last := data.Altitude[len(data.Altitude)-1]
+	seconds := elapsedSeconds(last.TimestampUTC, timestamp)
+	jump := math.Abs(float64(altitude - last.Data))
+	if jump <= r.MaxClimbFtMin*seconds/60+PLAUSIBLE_ALTITUDE_SLACK_FT {
+		data.AltitudeRejectRun = 0
+		return true
+	}
+	data.AltitudeRejectRun++
+	r.reject(data, fmt.Sprintf("altitude %.0f", altitude), fmt.Sprintf("%.0fft in %.0fs", jump, seconds))
+	return false
+}
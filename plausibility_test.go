@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+)
+
+func position(lat float32, long float32) *FormattedAdbsMsg {
+	return &FormattedAdbsMsg{
+		AircraftICAOAddr: "4840D6",
+		Lat:              Nullable[float32]{Value: lat, Valid: true},
+		Long:             Nullable[float32]{Value: long, Valid: true},
+	}
+}
+
+func altitude(ft float32) *FormattedAdbsMsg {
+	return &FormattedAdbsMsg{AircraftICAOAddr: "4840D6", Altitude: Nullable[float32]{Value: ft, Valid: true}}
+}
+
+func TestPlausibilityDropsJumps(t *testing.T) {
+	item := createNewDataEntry(position(52.30, 4.76), 0)
+	item = updateEntry(item, position(52.30, 4.76), 0)
+	// about 4km in 10s is 800kt, then a bad decode a few hundred km away
+	item = updateEntry(item, position(52.33, 4.80), 10_000)
+	item = updateEntry(item, position(50.10, 8.60), 11_000)
+	item = updateEntry(item, position(52.34, 4.81), 12_000)
+	for _, c := range item.Data.Coordinates {
+		if c.Lat < 52 {
+			t.Fatalf("Expected the jump to be dropped got %v", item.Data.Coordinates)
+		}
+	}
+	if item.Data.RejectedPoints != 1 {
+		t.Fatalf("Expected 1 rejected point got %d", item.Data.RejectedPoints)
+	}
+
+	item = updateEntry(item, altitude(3000), 12_000)
+	item = updateEntry(item, altitude(33000), 13_000)
+	item = updateEntry(item, altitude(3100), 14_000)
+	if last := item.Data.Altitude[len(item.Data.Altitude)-1]; last.Data == 33000 || item.Data.RejectedPoints != 2 {
+		t.Fatalf("Expected the altitude jump to be dropped got %v %d", item.Data.Altitude, item.Data.RejectedPoints)
+	}
+}
+
+func TestPlausibilityMovesOnFromBadFirstFix(t *testing.T) {
+	data := CollectedData{Icao: "4840D6", Coordinates: []CordinatesOverTime{{Lat: 10, Long: 10}}}
+	for i := 1; i <= PLAUSIBLE_MAX_REJECTS_IN_ROW; i++ {
+		if plausibility.acceptPosition(&data, 52.30, 4.76, int64(i*1000)) {
+			t.Fatalf("Expected reject %d to be dropped", i)
+		}
+	}
+	if plausibility.acceptPosition(&data, 52.30, 4.76, 6000) == false || data.PositionRejectRun != 0 {
+		t.Fatalf("Expected the track to move on after %d rejects got %v", PLAUSIBLE_MAX_REJECTS_IN_ROW, data)
+	}
+	if data.RejectedPoints != PLAUSIBLE_MAX_REJECTS_IN_ROW {
+		t.Fatalf("Expected %d rejected points got %d", PLAUSIBLE_MAX_REJECTS_IN_ROW, data.RejectedPoints)
+	}
+}
+
+func TestPlausibilityRange(t *testing.T) {
+	receiverLocation = Nullable[LatLong]{Value: LatLong{Lat: 52.258, Long: 3.918}, Valid: true}
+	defer func() { receiverLocation = Nullable[LatLong]{Valid: false} }()
+	data := CollectedData{Icao: "4840D6"}
+	if plausibility.acceptPosition(&data, 52.30, 4.76, 0) == false {
+		t.Fatalf("Expected a position 60km out to be accepted")
+	}
+	if plausibility.acceptPosition(&data, -33.9, 151.2, 0) {
+		t.Fatalf("Expected a position on the other side of the world to be dropped")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	decoder "github.com/kc8/dump-1090-aggergator/decoder"
+)
+
+const (
+	// odd and even frames further apart than this may be from different CPR zones
+	CPR_PAIR_MAX_AGE_MS         = 10_000
+	CPR_SURFACE_PAIR_MAX_AGE_MS = 25_000
+	// the last known position is only trusted as a local reference for this long
+	CPR_REFERENCE_MAX_AGE_MS = 600_000
+)
+
+type LatLong struct {
+	Lat  float64
+	Long float64
+}
+
+// set by -receiverLocation, when valid single CPR frames can be decoded against it
+var receiverLocation Nullable[LatLong]
+
+type CPROverTime struct {
+	Frame        decoder.CPRFrame `json:"frame"`
+	TimestampUTC int64            `json:"timestamp"`
+}
+
+func parseLatLong(txt string) (LatLong, error) {
+	parts := strings.Split(txt, ",")
+	if len(parts) != 2 {
+		return LatLong{}, errors.New(fmt.Sprintf("Expected lat,long got %s", txt))
+	}
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if latErr != nil {
+		return LatLong{}, latErr
+	}
+	long, longErr := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if longErr != nil {
+		return LatLong{}, longErr
+	}
+	if lat < -90 || lat > 90 || long < -180 || long > 180 {
+		return LatLong{}, errors.New(fmt.Sprintf("Lat,long out of range %s", txt))
+	}
+	return LatLong{Lat: lat, Long: long}, nil
+}
+
+// cprReference is the position single frames are decoded against, the aircrafts own
+// last position if it is recent, otherwise the receiver
+func cprReference(data *CollectedData, timestamp int64) (LatLong, bool) {
+	if len(data.Coordinates) > 0 {
+		last := data.Coordinates[len(data.Coordinates)-1]
+		if timestamp-last.TimestampUTC <= CPR_REFERENCE_MAX_AGE_MS {
+			return LatLong{Lat: float64(last.Lat), Long: float64(last.Long)}, true
+		}
+	}
+	if receiverLocation.Valid {
+		return receiverLocation.Value, true
+	}
+	return LatLong{}, false
+}
+
+// resolvePosition stores the CPR frame on the aircraft and tries a global decode with the
+// other half of the pair, falling back to a local decode against a reference position
+func resolvePosition(data *CollectedData, msg *FormattedAdbsMsg, timestamp int64) (Nullable[float32], Nullable[float32]) {
+	if msg.Lat.Valid && msg.Long.Valid {
+		return msg.Lat, msg.Long
+	}
+	if msg.CPR.Valid == false {
+		return Nullable[float32]{Valid: false}, Nullable[float32]{Valid: false}
+	}
+	frame := msg.CPR.Value
+	current := Nullable[CPROverTime]{Value: CPROverTime{Frame: frame, TimestampUTC: timestamp}, Valid: true}
+	if frame.Odd {
+		data.OddCPR = current
+	} else {
+		data.EvenCPR = current
+	}
+
+	reference, hasReference := cprReference(data, timestamp)
+	maxPairAge := int64(CPR_PAIR_MAX_AGE_MS)
+	if frame.Surface {
+		maxPairAge = CPR_SURFACE_PAIR_MAX_AGE_MS
+	}
+	if data.EvenCPR.Valid && data.OddCPR.Valid &&
+		data.EvenCPR.Value.Frame.Surface == data.OddCPR.Value.Frame.Surface &&
+		abs(data.EvenCPR.Value.TimestampUTC-data.OddCPR.Value.TimestampUTC) <= maxPairAge &&
+		(frame.Surface == false || hasReference) {
+		lat, long, ok := decoder.GlobalPosition(
+			data.EvenCPR.Value.Frame,
+			data.OddCPR.Value.Frame,
+			frame,
+			reference.Lat,
+			reference.Long)
+		if ok {
+			return Nullable[float32]{Value: float32(lat), Valid: true}, Nullable[float32]{Value: float32(long), Valid: true}
+		}
+	}
+	if hasReference {
+		lat, long := decoder.LocalPosition(frame, reference.Lat, reference.Long)
+		return Nullable[float32]{Value: float32(lat), Valid: true}, Nullable[float32]{Value: float32(long), Valid: true}
+	}
+	return Nullable[float32]{Valid: false}, Nullable[float32]{Valid: false}
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	decoder "github.com/kc8/dump-1090-aggergator/decoder"
+)
+
+func cprMsg(odd bool, lat uint32, lon uint32) *FormattedAdbsMsg {
+	return &FormattedAdbsMsg{
+		AircraftICAOAddr: "40621D",
+		CPR: Nullable[decoder.CPRFrame]{
+			Value: decoder.CPRFrame{Odd: odd, Lat: lat, Lon: lon},
+			Valid: true,
+		},
+	}
+}
+
+func TestResolvePositionPairsFrames(t *testing.T) {
+	data := CollectedData{Icao: "40621D"}
+	lat, long := resolvePosition(&data, cprMsg(true, 74158, 50194), 1000)
+	if lat.Valid || long.Valid {
+		t.Fatalf("Expected no position from a single frame without a reference got %v %v", lat, long)
+	}
+	lat, long = resolvePosition(&data, cprMsg(false, 93000, 51372), 2000)
+	if lat.Valid == false || long.Valid == false {
+		t.Fatalf("Expected a position from an odd/even pair")
+	}
+	if math.Abs(float64(lat.Value)-52.2572) > 0.0001 || math.Abs(float64(long.Value)-3.91937) > 0.0001 {
+		t.Fatalf("Expected 52.2572, 3.91937 got %f, %f", lat.Value, long.Value)
+	}
+}
+
+func TestResolvePositionStalePairUsesReceiver(t *testing.T) {
+	receiverLocation = Nullable[LatLong]{Value: LatLong{Lat: 52.258, Long: 3.918}, Valid: true}
+	defer func() { receiverLocation = Nullable[LatLong]{Valid: false} }()
+
+	data := CollectedData{Icao: "40621D"}
+	resolvePosition(&data, cprMsg(true, 74158, 50194), 1000)
+	lat, long := resolvePosition(&data, cprMsg(false, 93000, 51372), 1000+CPR_PAIR_MAX_AGE_MS+1)
+	if lat.Valid == false || long.Valid == false {
+		t.Fatalf("Expected a local position against the receiver")
+	}
+	if math.Abs(float64(lat.Value)-52.2572) > 0.0001 || math.Abs(float64(long.Value)-3.91937) > 0.0001 {
+		t.Fatalf("Expected 52.2572, 3.91937 got %f, %f", lat.Value, long.Value)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	decoder "github.com/kc8/dump-1090-aggergator/decoder"
+)
+
+// receiver is one piaware / dump1090 / readsb feed, every message read from it is tagged with its Id
+type receiver struct {
+	Id     string
+	Host   string
+	Port   string
+	Format string
+}
+
+func (r receiver) String() string {
+	return fmt.Sprintf("%s (%s:%s %s)", r.Id, r.Host, r.Port, r.Format)
+}
+
+// receiverList is a repeatable -receiver flag
+type receiverList []receiver
+
+func (l *receiverList) String() string {
+	specs := make([]string, 0, len(*l))
+	for _, r := range *l {
+		specs = append(specs, fmt.Sprintf("%s=%s:%s/%s", r.Id, r.Host, r.Port, r.Format))
+	}
+	return strings.Join(specs, ",")
+}
+
+func (l *receiverList) Set(spec string) error {
+	r, err := parseReceiver(spec)
+	if err != nil {
+		return err
+	}
+	for _, existing := range *l {
+		if existing.Id == r.Id {
+			return errors.New(fmt.Sprintf("Receiver id %s is used more than once", r.Id))
+		}
+	}
+	*l = append(*l, r)
+	return nil
+}
+
+// parseReceiver reads [id=]host[:port][/format], the port defaults to the formats usual port
+// and the id defaults to host:port
+func parseReceiver(spec string) (receiver, error) {
+	result := receiver{Format: "sbs"}
+	rest := spec
+	if idx := strings.Index(rest, "="); idx >= 0 {
+		result.Id = rest[:idx]
+		rest = rest[idx+1:]
+	}
+	if idx := strings.LastIndex(rest, "/"); idx >= 0 {
+		result.Format = rest[idx+1:]
+		rest = rest[:idx]
+	}
+	defaultPort, ok := DEFAULT_FORMAT_PORTS[result.Format]
+	if ok == false {
+		return receiver{}, errors.New(fmt.Sprintf("Unknown feed format %s in receiver %s", result.Format, spec))
+	}
+	host, port, splitErr := net.SplitHostPort(rest)
+	if splitErr != nil {
+		host = rest
+		port = defaultPort
+	}
+	if host == "" {
+		return receiver{}, errors.New(fmt.Sprintf("Receiver %s is missing a host", spec))
+	}
+	result.Host = host
+	result.Port = port
+	if result.Id == "" {
+		result.Id = net.JoinHostPort(host, port)
+	}
+	return result, nil
+}
+
+func startReceiver(
+	ctx context.Context,
+	rcv receiver,
+	jsonPath string,
+	pollInterval time.Duration,
+	done chan bool,
+	itemQueue *modifyStoQueue) {
+	Log(fmt.Sprintf("Starting receiver %s", rcv), INFO)
+	switch rcv.Format {
+	case "sbs":
+		go readData(ctx, rcv, itemQueue, ParseCSVFormat)
+	case "avr":
+		go readData(ctx, rcv, itemQueue, newAVRParser(decoder.New()))
+	case "beast":
+		go readBeastData(ctx, rcv, itemQueue)
+	case "json":
+		go pollAircraftJSON(ctx, rcv, fmt.Sprintf("http://%s%s", net.JoinHostPort(rcv.Host, rcv.Port), jsonPath), pollInterval, done, itemQueue)
+	default:
+		Log(fmt.Sprintf("Unknown feed format %s expected sbs, beast, avr or json", rcv.Format), FATAL)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestParseReceiver(t *testing.T) {
+	cases := map[string]receiver{
+		"north=10.0.0.5:30105/beast": {Id: "north", Host: "10.0.0.5", Port: "30105", Format: "beast"},
+		"south=piaware.local/avr":    {Id: "south", Host: "piaware.local", Port: "30002", Format: "avr"},
+		"piaware.local":              {Id: "piaware.local:30003", Host: "piaware.local", Port: "30003", Format: "sbs"},
+	}
+	for spec, expected := range cases {
+		r, err := parseReceiver(spec)
+		if err != nil {
+			t.Fatalf("Expected %s to parse got err %s", spec, err.Error())
+		}
+		if r != expected {
+			t.Fatalf("Expected %s to parse to %v got %v", spec, expected, r)
+		}
+	}
+	if _, err := parseReceiver("north=10.0.0.5/mlat"); err == nil {
+		t.Fatalf("Expected an unknown format to fail")
+	}
+}
+
+func TestReceiverListRejectsDuplicateIds(t *testing.T) {
+	var list receiverList
+	if err := list.Set("north=10.0.0.5"); err != nil {
+		t.Fatalf("Expected first receiver to be added got err %s", err.Error())
+	}
+	if err := list.Set("north=10.0.0.6"); err == nil {
+		t.Fatalf("Expected a duplicate id to fail")
+	}
+}
+
+func TestUpdateEntryRecordsReceivers(t *testing.T) {
+	item := createNewDataEntry(&FormattedAdbsMsg{AircraftICAOAddr: "A1B2C3", ReceiverId: "north"}, 1000)
+	item = updateEntry(item, &FormattedAdbsMsg{AircraftICAOAddr: "A1B2C3", ReceiverId: "south"}, 2000)
+	item = updateEntry(item, &FormattedAdbsMsg{AircraftICAOAddr: "A1B2C3", ReceiverId: "north"}, 3000)
+	if len(item.Data.Receivers) != 2 || item.Data.Receivers[0] != "north" || item.Data.Receivers[1] != "south" {
+		t.Fatalf("Expected receivers north and south got %v", item.Data.Receivers)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Captures are gzipped text, one record per line
+// <receive unix ms>\t<receiver id>\t<format>\t<payload>
+// the payload is the line as read for sbs and avr, the unescaped frame as hex for beast
+// and the compacted body for json
+const (
+	CAPTURE_FILE_PREFIX = "capture-"
+	CAPTURE_FILE_SUFFIX = ".tsv.gz"
+	CAPTURE_FIELD_SEP   = "\t"
+)
+
+// set by -record, nil when the feed is not being recorded
+var feedRecording *feedRecorder
+
+type captureRecord struct {
+	ReceivedUTC int64
+	ReceiverId  string
+	Format      string
+	Payload     []byte
+}
+
+type feedRecorder struct {
+	dir         string
+	rotateEvery time.Duration
+	file        *os.File
+	gz          *gzip.Writer
+	openedAt    time.Time
+	mutex       sync.Mutex
+}
+
+func newFeedRecorder(dir string, rotateEvery time.Duration) (*feedRecorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &feedRecorder{
+		dir:         dir,
+		rotateEvery: rotateEvery,
+	}, nil
+}
+
+func (r *feedRecorder) rotate(now time.Time) error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+	name := filepath.Join(r.dir, CAPTURE_FILE_PREFIX+now.UTC().Format("20060102T150405.000Z")+CAPTURE_FILE_SUFFIX)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.gz = gzip.NewWriter(f)
+	r.openedAt = now
+	Log(fmt.Sprintf("Recording feed to %s", name), INFO)
+	return nil
+}
+
+func (r *feedRecorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	gzErr := r.gz.Close()
+	fileErr := r.file.Close()
+	r.file = nil
+	r.gz = nil
+	if gzErr != nil {
+		return gzErr
+	}
+	return fileErr
+}
+
+// Record is safe to call on a nil recorder, it does nothing
+func (r *feedRecorder) Record(received time.Time, rcv receiver, payload []byte) error {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.file == nil || (r.rotateEvery > 0 && received.Sub(r.openedAt) >= r.rotateEvery) {
+		if err := r.rotate(received); err != nil {
+			return err
+		}
+	}
+	line := fmt.Sprintf("%d%s%s%s%s%s%s\n",
+		received.UTC().UnixMilli(), CAPTURE_FIELD_SEP,
+		rcv.Id, CAPTURE_FIELD_SEP,
+		rcv.Format, CAPTURE_FIELD_SEP,
+		bytes.TrimRight(payload, "\r\n"))
+	_, err := r.gz.Write([]byte(line))
+	return err
+}
+
+func (r *feedRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.closeFile()
+}
+
+func recordFeed(received time.Time, rcv receiver, payload []byte) {
+	if err := feedRecording.Record(received, rcv, payload); err != nil {
+		Log(fmt.Sprintf("Failed to record feed from %s due to %s", rcv.Id, err.Error()), ERROR)
+	}
+}
+
+func encodeBeastRecord(frame *BeastFrame) []byte {
+	raw := make([]byte, 0, 1+BEAST_MLAT_TIMESTAMP_LEN+BEAST_SIGNAL_LEN+len(frame.Data))
+	raw = append(raw, frame.Type)
+	for shift := 40; shift >= 0; shift -= 8 {
+		raw = append(raw, byte(frame.MLATTimestamp>>uint(shift)))
+	}
+	raw = append(raw, frame.Signal)
+	raw = append(raw, frame.Data...)
+	result := make([]byte, hex.EncodedLen(len(raw)))
+	hex.Encode(result, raw)
+	return result
+}
+
+func decodeBeastRecord(payload []byte) (*BeastFrame, error) {
+	raw := make([]byte, hex.DecodedLen(len(payload)))
+	if _, err := hex.Decode(raw, payload); err != nil {
+		return nil, err
+	}
+	if len(raw) < 1+BEAST_MLAT_TIMESTAMP_LEN+BEAST_SIGNAL_LEN {
+		return nil, errors.New(fmt.Sprintf("Beast record too short %q", payload))
+	}
+	var mlat uint64
+	for _, t := range raw[1 : 1+BEAST_MLAT_TIMESTAMP_LEN] {
+		mlat = mlat<<8 | uint64(t)
+	}
+	return &BeastFrame{
+		Type:          raw[0],
+		MLATTimestamp: mlat,
+		Signal:        raw[1+BEAST_MLAT_TIMESTAMP_LEN],
+		Data:          raw[1+BEAST_MLAT_TIMESTAMP_LEN+BEAST_SIGNAL_LEN:],
+	}, nil
+}
+
+func compactJSONRecord(body []byte) []byte {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, body); err != nil {
+		return bytes.ReplaceAll(body, []byte("\n"), []byte(" "))
+	}
+	return compacted.Bytes()
+}
+
+func parseCaptureRecord(line []byte) (captureRecord, error) {
+	fields := strings.SplitN(string(line), CAPTURE_FIELD_SEP, 4)
+	if len(fields) != 4 {
+		return captureRecord{}, errors.New(fmt.Sprintf("Capture record has %d fields expected 4", len(fields)))
+	}
+	received, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return captureRecord{}, err
+	}
+	return captureRecord{
+		ReceivedUTC: received,
+		ReceiverId:  fields[1],
+		Format:      fields[2],
+		Payload:     []byte(fields[3]),
+	}, nil
+}
+
+// readCaptureFile calls visit for every record in order, a capture cut short by the
+// collector being killed is read up to where it ends
+func readCaptureFile(path string, visit func(captureRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var reader io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, gzErr := gzip.NewReader(f)
+		if gzErr != nil {
+			return gzErr
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		record, err := parseCaptureRecord(scanner.Bytes())
+		if err != nil {
+			Log(fmt.Sprintf("Skipping bad capture record in %s due to %s", path, err.Error()), WARN)
+			continue
+		}
+		if err := visit(record); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil && errors.Is(err, io.ErrUnexpectedEOF) == false {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+const TEST_SBS_LINE = "MSG,3,1,1,A1B2C3,1,2024/10/08,12:00:00.000,2024/10/08,12:00:00.000,,35000,,,40.50000,-73.50000,,,0,0,0,0\r"
+
+func TestRecordAndReplayCapture(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := newFeedRecorder(dir, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected recorder got err %s", err.Error())
+	}
+	start := time.UnixMilli(1_700_000_000_000)
+	frame, _ := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+
+	sbs := receiver{Id: "north", Format: "sbs"}
+	beast := receiver{Id: "south", Format: "beast"}
+	if err := recorder.Record(start, sbs, []byte(TEST_SBS_LINE)); err != nil {
+		t.Fatalf("Expected record got err %s", err.Error())
+	}
+	// past the rotation interval so this lands in a second file
+	beastFrame := &BeastFrame{Type: BEAST_TYPE_MODE_S_LONG, MLATTimestamp: 0x1a000102, Signal: 0x80, Data: frame}
+	if err := recorder.Record(start.Add(2*time.Minute), beast, encodeBeastRecord(beastFrame)); err != nil {
+		t.Fatalf("Expected record got err %s", err.Error())
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Expected close got err %s", err.Error())
+	}
+
+	files, err := captureFiles([]string{dir})
+	if err != nil {
+		t.Fatalf("Expected capture files got err %s", err.Error())
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 rotated capture files got %d", len(files))
+	}
+
+	replay := newReplayer()
+	var messages []*FormattedAdbsMsg
+	var received []int64
+	for _, file := range files {
+		err := readCaptureFile(file, func(record captureRecord) error {
+			received = append(received, record.ReceivedUTC)
+			results, err := replay.parse(record)
+			if err != nil {
+				return err
+			}
+			messages = append(messages, results...)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Expected to read %s got err %s", file, err.Error())
+		}
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 replayed messages got %d", len(messages))
+	}
+	if received[0] != start.UnixMilli() || received[1] != start.Add(2*time.Minute).UnixMilli() {
+		t.Fatalf("Expected receive timestamps to be kept got %v", received)
+	}
+	if messages[0].AircraftICAOAddr != "A1B2C3" || messages[0].ReceiverId != "north" || messages[0].Altitude.Value != 35000 {
+		t.Fatalf("Expected A1B2C3 at 35000 from north got %s %v from %s", messages[0].AircraftICAOAddr, messages[0].Altitude, messages[0].ReceiverId)
+	}
+	if messages[1].CallsignFlightNum != "KLM1023" || messages[1].ReceiverId != "south" || messages[1].MLATTimestamp.Value != 0x1a000102 {
+		t.Fatalf("Expected KLM1023 from south with its MLAT timestamp got %s from %s %v", messages[1].CallsignFlightNum, messages[1].ReceiverId, messages[1].MLATTimestamp)
+	}
+}
+
+func TestReplayDelay(t *testing.T) {
+	if d := replayDelay(1000, 3000, 1); d != 2*time.Second {
+		t.Fatalf("Expected real time delay of 2s got %s", d)
+	}
+	if d := replayDelay(1000, 3000, 4); d != 500*time.Millisecond {
+		t.Fatalf("Expected 4x delay of 500ms got %s", d)
+	}
+	if d := replayDelay(1000, 3000, 0); d != 0 {
+		t.Fatalf("Expected no delay as fast as possible got %s", d)
+	}
+	if d := replayDelay(3000, 1000, 1); d != 0 {
+		t.Fatalf("Expected no delay going back in time got %s", d)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"slices"
+	"time"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+const (
+	SPLIT_GAP      = "gap"
+	SPLIT_CALLSIGN = "callsign"
+	SPLIT_TAKEOFF  = "takeoff"
+)
+
+// segmentRules decide when an aircraft that is still in range has started a new flight
+type segmentRules struct {
+	Gap        time.Duration // 0 never splits on a gap
+	OnCallsign bool
+	OnTakeoff  bool
+}
+
+// callsign splits are off by default, they would leave every flights callsign history one entry long
+var flightSegments = segmentRules{Gap: 30 * time.Minute, OnTakeoff: true}
+
+func addSegmentFlags(fs *flag.FlagSet) {
+	fs.DurationVar(&flightSegments.Gap, "splitGap", flightSegments.Gap, "Start a new flight when an aircraft was not heard for this long, 0 turns it off")
+	fs.BoolVar(&flightSegments.OnCallsign, "splitOnCallsign", flightSegments.OnCallsign, "Start a new flight when the callsign changes, each flight then only has the one callsign")
+	fs.BoolVar(&flightSegments.OnTakeoff, "splitOnTakeoff", flightSegments.OnTakeoff, "Start a new flight when an aircraft that landed takes off again")
+}
+
+// splitReason returns which rule says msg starts a new flight, or "" when it belongs to data
+func (r segmentRules) splitReason(data CollectedData, msg *FormattedAdbsMsg, currentTimeStamp int64) string {
+	lastHeard := max(data.LastSeen, data.FirstSeen)
+	if r.Gap > 0 && currentTimeStamp-lastHeard >= r.Gap.Milliseconds() {
+		return SPLIT_GAP
+	}
+	if r.OnCallsign && msg.CallsignFlightNum != "" && len(data.Callsign) > 0 &&
+		data.Callsign[len(data.Callsign)-1].Data != msg.CallsignFlightNum {
+		return SPLIT_CALLSIGN
+	}
+	// taxiing out and taking off is one flight, landing and taking off again is two unless it was a touch and go
+	if r.OnTakeoff && msg.IsOnGround.Valid && msg.IsOnGround.Value == 0 && len(data.OnGround) >= 2 {
+		latest := data.OnGround[len(data.OnGround)-1]
+		wasAirborne := data.OnGround[len(data.OnGround)-2].Data == false
+		if latest.Data && wasAirborne && currentTimeStamp-latest.TimestampUTC > TOUCH_AND_GO_MAX_MS {
+			return SPLIT_TAKEOFF
+		}
+	}
+	return ""
+}
+
+// finishSegment moves a completed flight off the aircrafts key so it can be written while the
+// next flight keeps aggregating under the ICAO
+func finishSegment(item storage.MapItem[CollectedData]) storage.MapItem[CollectedData] {
+	item.Key = fmt.Sprintf("%s-%d", item.Data.Icao, item.Data.FirstSeen)
+	item.Data.Finished = true
+	return item
+}
+
+// startSegment is the flight raw starts after previous. It is built from previous rather than
+// createNewDataEntry so there is no second tail number lookup while the queue waits on it, and it
+// keeps whether the aircraft was on the ground so a takeoff from where previous landed is detected
+func startSegment(previous storage.MapItem[CollectedData], raw *FormattedAdbsMsg, currentTimeStamp int64) storage.MapItem[CollectedData] {
+	next := storage.MapItem[CollectedData]{
+		Key: raw.AircraftICAOAddr,
+		Data: CollectedData{
+			FirstSeen:  currentTimeStamp,
+			Icao:       previous.Data.Icao,
+			TailNumber: previous.Data.TailNumber,
+			Receivers:  slices.Clone(previous.Data.Receivers),
+		},
+	}
+	if len(previous.Data.OnGround) > 0 {
+		next.Data.OnGround = []DataOverTime[bool]{previous.Data.OnGround[len(previous.Data.OnGround)-1]}
+	}
+	return updateEntry(next, raw, currentTimeStamp)
+}
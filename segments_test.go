@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+func TestSplitReason(t *testing.T) {
+	rules := segmentRules{Gap: 30 * time.Minute, OnCallsign: true, OnTakeoff: true}
+	ground := Nullable[int]{Value: -1, Valid: true}
+	air := Nullable[int]{Value: 0, Valid: true}
+
+	data := createNewDataEntry(&FormattedAdbsMsg{AircraftICAOAddr: "4840D6", CallsignFlightNum: "KLM1023"}, 0).Data
+	if reason := rules.splitReason(data, &FormattedAdbsMsg{CallsignFlightNum: "KLM1023"}, 60_000); reason != "" {
+		t.Fatalf("Expected the same flight got %s", reason)
+	}
+	if reason := rules.splitReason(data, &FormattedAdbsMsg{}, 31*60_000); reason != SPLIT_GAP {
+		t.Fatalf("Expected a gap split got %q", reason)
+	}
+	if reason := rules.splitReason(data, &FormattedAdbsMsg{CallsignFlightNum: "KLM1024"}, 60_000); reason != SPLIT_CALLSIGN {
+		t.Fatalf("Expected a callsign split got %q", reason)
+	}
+
+	// taxi out then take off stays one flight
+	data.OnGround = []DataOverTime[bool]{{Data: true, TimestampUTC: 0}}
+	if reason := rules.splitReason(data, &FormattedAdbsMsg{IsOnGround: air}, 60_000); reason != "" {
+		t.Fatalf("Expected taxi out and take off to be one flight got %s", reason)
+	}
+	// landed and taking off again
+	data.OnGround = []DataOverTime[bool]{{Data: false, TimestampUTC: 0}, {Data: true, TimestampUTC: 60_000}}
+	if reason := rules.splitReason(data, &FormattedAdbsMsg{IsOnGround: ground}, 120_000); reason != "" {
+		t.Fatalf("Expected taxiing to stay with the flight got %s", reason)
+	}
+	if reason := rules.splitReason(data, &FormattedAdbsMsg{IsOnGround: air}, 90_000); reason != "" {
+		t.Fatalf("Expected a touch and go to stay one flight got %s", reason)
+	}
+	if reason := rules.splitReason(data, &FormattedAdbsMsg{IsOnGround: air}, 600_000); reason != SPLIT_TAKEOFF {
+		t.Fatalf("Expected a takeoff split got %q", reason)
+	}
+	if reason := (segmentRules{}).splitReason(data, &FormattedAdbsMsg{IsOnGround: air, CallsignFlightNum: "X"}, 120_000_000); reason != "" {
+		t.Fatalf("Expected no rules to never split got %s", reason)
+	}
+}
+
+func TestAggregateSplitsFlights(t *testing.T) {
+	defer func(rules segmentRules) { flightSegments = rules }(flightSegments)
+	flightSegments.OnCallsign = true
+	first := &FormattedAdbsMsg{AircraftICAOAddr: "4840D6", CallsignFlightNum: "KLM1023"}
+	second := &FormattedAdbsMsg{AircraftICAOAddr: "4840D6", CallsignFlightNum: "KLM1024"}
+	item := aggregate(storage.MapItem[CollectedData]{}, false, first, 1000)[0]
+	item = aggregate(item, true, first, 2000)[0]
+
+	items := aggregate(item, true, second, 3000)
+	if len(items) != 2 {
+		t.Fatalf("Expected the finished flight and a new one got %d", len(items))
+	}
+	finished, next := items[0], items[1]
+	if finished.Key != "4840D6-1000" || finished.Data.Finished == false || isReadyToInsert(finished, 3000) == false {
+		t.Fatalf("Expected 4840D6-1000 finished and ready to insert got %s %v", finished.Key, finished.Data.Finished)
+	}
+	if next.Key != "4840D6" || next.Data.FirstSeen != 3000 || next.Data.Callsign[0].Data != "KLM1024" {
+		t.Fatalf("Expected a new flight under 4840D6 from 3000 as KLM1024 got %s %d %v", next.Key, next.Data.FirstSeen, next.Data.Callsign)
+	}
+}
+
+func TestTakeoffAfterLandingStartsWithTakeoff(t *testing.T) {
+	ground := Nullable[int]{Value: -1, Valid: true}
+	air := Nullable[int]{Value: 0, Valid: true}
+	at := func(onGround Nullable[int], altitude float32, speed float32) *FormattedAdbsMsg {
+		return &FormattedAdbsMsg{
+			AircraftICAOAddr: "4840D6",
+			IsOnGround:       onGround,
+			Altitude:         Nullable[float32]{Value: altitude, Valid: onGround.Value == 0},
+			GroundSpeed:      Nullable[float32]{Value: speed, Valid: true},
+			Lat:              Nullable[float32]{Value: 52.31, Valid: true},
+			Long:             Nullable[float32]{Value: 4.76, Valid: true},
+		}
+	}
+	// lands, taxis in, waits and departs again half an hour later
+	item := aggregate(storage.MapItem[CollectedData]{}, false, at(air, 1500, 140), 0)[0]
+	item = aggregate(item, true, at(air, 500, 130), 60_000)[0]
+	item = aggregate(item, true, at(ground, 0, 110), 120_000)[0]
+	item = aggregate(item, true, at(ground, 0, 15), 300_000)[0]
+	item.Data.TailNumber = "PH-BXA"
+	items := aggregate(item, true, at(air, 300, 150), 1_801_000)
+	if len(items) != 2 {
+		t.Fatalf("Expected the departure to start a new flight got %d", len(items))
+	}
+	next := items[1]
+	if next.Data.TailNumber != "PH-BXA" || next.Data.Icao != "4840D6" || next.Data.FirstSeen != 1_801_000 {
+		t.Fatalf("Expected the new flight to keep 4840D6 PH-BXA from 1801000 got %s %s %d", next.Data.Icao, next.Data.TailNumber, next.Data.FirstSeen)
+	}
+	if next.Data.MsgCount != 1 || len(next.Data.Coordinates) != 1 || len(next.Data.Altitude) != 1 {
+		t.Fatalf("Expected the splitting message to be kept got %d %v %v", next.Data.MsgCount, next.Data.Coordinates, next.Data.Altitude)
+	}
+	events := detectEvents(next.Data)
+	if len(events) != 1 || events[0].Type != EVENT_TAKEOFF {
+		t.Fatalf("Expected the new flight to start with a takeoff got %v from %v", events, next.Data.OnGround)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"math"
+
+	geo "github.com/kc8/dump-1090-aggergator/geo"
+)
+
+// simplifyRules thin out the series of a flight before it is written, each tolerance is in the
+// units of the series and 0 keeps every change
+type simplifyRules struct {
+	TrackToleranceM   float64 // positions closer than this to the simplified line are dropped
+	AltitudeFt        float64
+	SpeedKt           float64
+	HeadingDeg        float64
+	VerticalRateFtMin float64
+}
+
+var simplification = simplifyRules{TrackToleranceM: 50, AltitudeFt: 100, SpeedKt: 5, HeadingDeg: 3, VerticalRateFtMin: 250}
+
+func addSimplifyFlags(fs *flag.FlagSet) {
+	fs.Float64Var(&simplification.TrackToleranceM, "simplifyTrack", simplification.TrackToleranceM, "Meters, drop positions closer than this to the line through the rest of the track, 0 keeps every position")
+	fs.Float64Var(&simplification.AltitudeFt, "simplifyAltitude", simplification.AltitudeFt, "Ft, only store an altitude that moved at least this far from the last one stored, 0 keeps every change")
+	fs.Float64Var(&simplification.SpeedKt, "simplifySpeed", simplification.SpeedKt, "Knots, only store a ground speed that changed at least this much, 0 keeps every change")
+	fs.Float64Var(&simplification.HeadingDeg, "simplifyHeading", simplification.HeadingDeg, "Degrees, only store a track that turned at least this much, 0 keeps every change")
+	fs.Float64Var(&simplification.VerticalRateFtMin, "simplifyVerticalRate", simplification.VerticalRateFtMin, "Ft/min, only store a vertical rate that changed at least this much, 0 keeps every change")
+}
+
+// offTrackMeters is how far p is from the line a to b, on a flat projection around a which is
+// plenty accurate over the length of one segment
+func offTrackMeters(p CordinatesOverTime, a CordinatesOverTime, b CordinatesOverTime) float64 {
+	const METERS_PER_DEGREE = geo.EARTH_RADIUS_KM * 1000 * math.Pi / 180
+	scale := math.Cos(float64(a.Lat) * math.Pi / 180)
+	project := func(c CordinatesOverTime) (float64, float64) {
+		// across the antimeridian -179 is 2 degrees east of 179
+		dLong := math.Remainder(float64(c.Long-a.Long), 360)
+		return dLong * scale * METERS_PER_DEGREE, float64(c.Lat-a.Lat) * METERS_PER_DEGREE
+	}
+	px, py := project(p)
+	bx, by := project(b)
+	length := bx*bx + by*by
+	if length == 0 {
+		return math.Hypot(px, py)
+	}
+	// closest point on the segment
+	t := math.Max(0, math.Min(1, (px*bx+py*by)/length))
+	return math.Hypot(px-t*bx, py-t*by)
+}
+
+// simplifyTrack is Douglas-Peucker, it keeps the first and last position and every position that
+// is more than toleranceM off the line between the positions kept around it
+func simplifyTrack(track []CordinatesOverTime, toleranceM float64) []CordinatesOverTime {
+	if toleranceM <= 0 || len(track) < 3 {
+		return track
+	}
+	keep := make([]bool, len(track))
+	keep[0], keep[len(track)-1] = true, true
+	// ranges still to look at, a stack rather than recursion so long flights can not overflow
+	stack := [][2]int{{0, len(track) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+		furthest, furthestDist := -1, toleranceM
+		for i := first + 1; i < last; i++ {
+			if d := offTrackMeters(track[i], track[first], track[last]); d > furthestDist {
+				furthest, furthestDist = i, d
+			}
+		}
+		if furthest < 0 {
+			continue
+		}
+		keep[furthest] = true
+		stack = append(stack, [2]int{first, furthest}, [2]int{furthest, last})
+	}
+	result := make([]CordinatesOverTime, 0)
+	for i, c := range track {
+		if keep[i] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// deadband keeps the first and last value and any value at least threshold from the last one kept
+func deadband[T int | float32](series []DataOverTime[T], threshold float64, diff func(a T, b T) float64) []DataOverTime[T] {
+	if threshold <= 0 || len(series) < 3 {
+		return series
+	}
+	result := []DataOverTime[T]{series[0]}
+	for _, point := range series[1 : len(series)-1] {
+		if diff(result[len(result)-1].Data, point.Data) >= threshold {
+			result = append(result, point)
+		}
+	}
+	return append(result, series[len(series)-1])
+}
+
+func absDiff[T int | float32](a T, b T) float64 {
+	return math.Abs(float64(a) - float64(b))
+}
+
+func headingDiff(a int, b int) float64 {
+	return geo.HeadingDiff(float64(a), float64(b))
+}
+
+// apply returns data with its track and numeric series simplified, callsigns, squawks and flags
+// only ever hold changes and are left alone
+func (r simplifyRules) apply(data CollectedData) CollectedData {
+	data.Coordinates = simplifyTrack(data.Coordinates, r.TrackToleranceM)
+	data.Altitude = deadband(data.Altitude, r.AltitudeFt, absDiff[float32])
+	data.GroundSpeed = deadband(data.GroundSpeed, r.SpeedKt, absDiff[float32])
+	data.HeadingTrack = deadband(data.HeadingTrack, r.HeadingDeg, headingDiff)
+	data.VerticalRate = deadband(data.VerticalRate, r.VerticalRateFtMin, absDiff[float32])
+	return data
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSimplifyTrack(t *testing.T) {
+	// a straight line east with a 2km dogleg north in the middle
+	track := []CordinatesOverTime{
+		{Lat: 52.0, Long: 4.0, TimestampUTC: 0},
+		{Lat: 52.0, Long: 4.1, TimestampUTC: 1000},
+		{Lat: 52.0001, Long: 4.2, TimestampUTC: 2000},
+		{Lat: 52.018, Long: 4.3, TimestampUTC: 3000},
+		{Lat: 52.0, Long: 4.4, TimestampUTC: 4000},
+		{Lat: 52.0, Long: 4.5, TimestampUTC: 5000},
+	}
+	simplified := simplifyTrack(track, 50)
+	expected := []int64{0, 2000, 3000, 4000, 5000}
+	if len(simplified) != len(expected) {
+		t.Fatalf("Expected points at %v got %v", expected, simplified)
+	}
+	for i, ts := range expected {
+		if simplified[i].TimestampUTC != ts {
+			t.Fatalf("Expected points at %v got %v", expected, simplified)
+		}
+	}
+	if len(simplifyTrack(track, 0)) != len(track) {
+		t.Fatalf("Expected a tolerance of 0 to keep every position")
+	}
+}
+
+func TestDeadband(t *testing.T) {
+	// a slow climb in 25ft steps only keeps every 100ft, the last point is always kept
+	climb := series(
+		DataOverTime[float32]{Data: 1000, TimestampUTC: 0},
+		DataOverTime[float32]{Data: 1025, TimestampUTC: 1},
+		DataOverTime[float32]{Data: 1050, TimestampUTC: 2},
+		DataOverTime[float32]{Data: 1075, TimestampUTC: 3},
+		DataOverTime[float32]{Data: 1100, TimestampUTC: 4},
+		DataOverTime[float32]{Data: 1125, TimestampUTC: 5},
+	)
+	if kept := deadband(climb, 100, absDiff[float32]); len(kept) != 3 || kept[1].Data != 1100 || kept[2].Data != 1125 {
+		t.Fatalf("Expected 1000 1100 1125 got %v", kept)
+	}
+	// 359 to 2 is a 3 degree turn
+	turn := series(DataOverTime[int]{Data: 358}, DataOverTime[int]{Data: 359}, DataOverTime[int]{Data: 2}, DataOverTime[int]{Data: 3})
+	if kept := deadband(turn, 3, headingDiff); len(kept) != 3 || kept[1].Data != 2 {
+		t.Fatalf("Expected 358 2 3 got %v", kept)
+	}
+}
+
+func TestUpdateEntryKeepsEveryChange(t *testing.T) {
+	item := createNewDataEntry(altitude(3000), 0)
+	for i, ft := range []float32{3000, 3000, 3100, 3200, 3200} {
+		item = updateEntry(item, altitude(ft), int64(i*1000))
+	}
+	if len(item.Data.Altitude) != 3 || item.Data.Altitude[2].Data != 3200 {
+		t.Fatalf("Expected 3000 3100 3200 got %v", item.Data.Altitude)
+	}
+}
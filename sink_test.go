@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+// errOutage is the error fakeSink says is retryable
+var errOutage = errors.New("database is locked")
+
+// fakeSink keeps flights in memory, insertErr makes every insert fail, failures fails only the
+// next few batches with errOutage and rejectIcao fails any batch with that aircraft in it
+type fakeSink struct {
+	mutex      sync.Mutex
+	records    []storage.FlightRecord
+	batches    []int
+	insertErr  error
+	failures   int
+	rejectIcao string
+	closed     bool
+}
+
+func (f *fakeSink) Insert(ctx context.Context, record storage.FlightRecord) error {
+	return f.InsertBatch(ctx, []storage.FlightRecord{record})
+}
+
+func (f *fakeSink) InsertBatch(ctx context.Context, records []storage.FlightRecord) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.insertErr != nil {
+		return f.insertErr
+	}
+	if f.failures > 0 {
+		f.failures--
+		return errOutage
+	}
+	for _, record := range records {
+		if record.Icao == f.rejectIcao {
+			return errors.New(fmt.Sprintf("can not store %s", record.Icao))
+		}
+	}
+	f.records = append(f.records, records...)
+	f.batches = append(f.batches, len(records))
+	return nil
+}
+
+func (f *fakeSink) Retryable(err error) bool {
+	return errors.Is(err, errOutage)
+}
+
+func (f *fakeSink) written() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.records)
+}
+
+func (f *fakeSink) Clean() error {
+	f.closed = true
+	return nil
+}
+
+// testWriting retries without waiting and spills to a file in dir
+func testWriting(dir string) writerSettings {
+	return writerSettings{BatchSize: 2, MaxAttempts: 3, RetryMin: time.Millisecond, RetryMax: time.Millisecond, DeadLetter: filepath.Join(dir, "dead.jsonl")}
+}
+
+func TestShutdownWritesToSink(t *testing.T) {
+	settings := testWriting(t.TempDir())
+	msg, _ := ParseCSVFormat([]byte(TEST_SBS_LINE))
+	store := storage.NewMapStorage[CollectedData]()
+	q := NewQueue(&store)
+	go q.run(make(chan Nullable[storage.MapItem[CollectedData]]))
+	defer q.stop()
+	item := aggregate(storage.MapItem[CollectedData]{}, false, msg, 1000)[0]
+	item.Data.Coordinates = []CordinatesOverTime{{Lat: 52.31, Long: 4.76, TimestampUTC: 1000}}
+
+	sink := &fakeSink{}
+	q.append(item)
+	shutdown(newFlightWriter(sink, settings), q, "")
+	if len(sink.records) != 1 || sink.records[0].Icao != msg.AircraftICAOAddr || len(sink.records[0].Positions) != 1 || sink.closed == false {
+		t.Fatalf("Expected %s with its position written and the sink closed got %v", msg.AircraftICAOAddr, sink.records)
+	}
+	if _, err := store.Search(item.Key, simpleKeyCompare); err == nil {
+		t.Fatalf("Expected %s to be removed once written", item.Key)
+	}
+
+	// a sink still down on shutdown spills the aircraft after -writeAttempts rather than keeping it forever
+	down := &fakeSink{insertErr: errOutage}
+	// a queue only ever adds an aircraft once
+	store = storage.NewMapStorage[CollectedData]()
+	q = NewQueue(&store)
+	go q.run(make(chan Nullable[storage.MapItem[CollectedData]]))
+	defer q.stop()
+	q.append(item)
+	shutdown(newFlightWriter(down, settings), q, "")
+	if _, err := store.Search(item.Key, simpleKeyCompare); err == nil || down.closed == false {
+		t.Fatalf("Expected %s out of storage and the sink to be closed got %v", item.Key, down.closed)
+	}
+	spilled, err := readDeadLetters(settings.DeadLetter)
+	if err != nil || len(spilled) != 1 || spilled[0].Key != item.Key {
+		t.Fatalf("Expected %s in the dead letter file got %v %v", item.Key, spilled, err)
+	}
+}
+
+func TestShutdownWaitsForScan(t *testing.T) {
+	store := storage.NewMapStorage[CollectedData]()
+	q := NewQueue(&store)
+	go q.run(make(chan Nullable[storage.MapItem[CollectedData]]))
+	defer q.stop()
+	q.append(finishedFlight("3C6586"))
+	q.sync()
+
+	sink := &fakeSink{}
+	writer := newFlightWriter(sink, testWriting(t.TempDir()))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	stopped := make(chan struct{})
+	go scanForEntryIntoDB(ctx, writer, done, stopped, 1, q)
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-stopped
+	close(done)
+	shutdown(writer, q, "")
+	if len(sink.records) != 1 || writer.deadLettered != 0 {
+		t.Fatalf("Expected 3C6586 written once got %v, %d spilled", sink.records, writer.deadLettered)
+	}
+}
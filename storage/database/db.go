@@ -9,7 +9,8 @@ import (
 	"strings"
 	"sync"
 
-	_ "github.com/mattn/go-sqlite3"
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+	sqlite3 "github.com/mattn/go-sqlite3"
 )
 
 // reference to db must always be ptr
@@ -18,11 +19,32 @@ type Db struct {
 	mutex       sync.Mutex
 }
 
+var _ storage.FlightSink = (*Db)(nil)
+
 const (
 	table_name = "aircraftData"
 )
 
+// New opens the database and brings its schema up to date
 func New(sqlLitefilename string, sqlitePath string) (*Db, error) {
+	result, openErr := Open(sqlLitefilename, sqlitePath)
+	if openErr != nil {
+		return nil, openErr
+	}
+	_, migrateErr := result.Migrate(context.Background())
+	if migrateErr != nil {
+		cleanResultErr := result.Clean()
+		if cleanResultErr != nil {
+			return nil, errors.New(fmt.Sprintf("[ERROR] Failed migrating the database and closing db due to various errors: %s AND %s", cleanResultErr.Error(), migrateErr.Error()))
+		}
+		return nil, errors.New(fmt.Sprintf("Failed to create Db due to: %s", migrateErr.Error()))
+	}
+
+	return result, nil
+}
+
+// Open opens the database without touching its schema, see Migrate
+func Open(sqlLitefilename string, sqlitePath string) (*Db, error) {
 	dbName := func() string {
 		if strings.Contains(*&sqlLitefilename, "/") {
 			return sqlLitefilename
@@ -34,7 +56,8 @@ func New(sqlLitefilename string, sqlitePath string) (*Db, error) {
 	if checkOrCreateEr != nil {
 		return nil, checkOrCreateEr
 	}
-	dbInstance, dbOpenErr := sql.Open("sqlite3", fullDbPath)
+	// foreign keys are per connection in sqlite, the driver turns them on for each one it opens
+	dbInstance, dbOpenErr := sql.Open("sqlite3", fullDbPath+"?_foreign_keys=on")
 	if dbOpenErr != nil {
 		return nil, dbOpenErr
 	}
@@ -44,15 +67,6 @@ func New(sqlLitefilename string, sqlitePath string) (*Db, error) {
 	if err := result.TestConnnection(); err != nil {
 		return nil, err
 	}
-	createTblErr := result.createTable()
-	if createTblErr != nil {
-		cleanResultErr := result.Clean()
-		if cleanResultErr != nil {
-			return nil, errors.New(fmt.Sprintf("[ERROR] Failed creating aircraft table and closing db due to various errors: %s AND %s", cleanResultErr.Error(), createTblErr.Error()))
-		}
-		return nil, errors.New(fmt.Sprintf("Failed to create Db due to: %s", createTblErr.Error()))
-	}
-
 	return result, nil
 }
 
@@ -84,20 +98,13 @@ func (d *Db) Clean() error {
 	return nil
 }
 
-func (d *Db) Insert(
-    ctx context.Context,
-	lastSeen    int64,
-	firstSeen   int64,
-	msgCount    uint64,
-	coordinates []byte,
-	icao         string,
-	tailNumber   string,
-	altitude     []byte,
-	groundSpeed  []byte,
-	headingTrack []byte, 
-	verticalRate []byte,
-	squawkCode   []byte, 
-	emergency    int) error {
+// Insert writes a finished flight, its events, positions and telemetry in one transaction
+func (d *Db) Insert(ctx context.Context, record storage.FlightRecord) error {
+	return d.InsertBatch(ctx, []storage.FlightRecord{record})
+}
+
+// InsertBatch writes every flight in one transaction, none of them are written when one fails
+func (d *Db) InsertBatch(ctx context.Context, records []storage.FlightRecord) error {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
@@ -105,8 +112,22 @@ func (d *Db) Insert(
 	if dbbeginErr != nil {
 		return dbbeginErr
 	}
-	defer tx.Commit()
+	for _, record := range records {
+		if err := insertFlight(tx, record); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
 
+// Retryable is true while another connection holds the database or a table locked
+func (d *Db) Retryable(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+func insertFlight(tx *sql.Tx, record storage.FlightRecord) error {
 	insertStatement := `
     insert into aircraftData(
         icao,
@@ -120,97 +141,118 @@ func (d *Db) Insert(
         groundSpeed,
         headingTrack,
         squawkCode,
-        verticalRate
+        verticalRate,
+        receivers,
+        callsign,
+        onGround,
+        ident,
+        squawkChange,
+        origin,
+        originRunway,
+        destination,
+        destinationRunway,
+        rejectedPoints
     )
-    values (
-        ?,
-        ?,
-        ?,
-        ?,
-        ?,
-        ?,
-        ?,
-        ?,
-        ?,
-        ?,
-        ?,
-        ?
-    );
+    values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
     `
 	stmt, prepErr := tx.Prepare(insertStatement)
 	if prepErr != nil {
-		tx.Rollback()
 		return prepErr
 	}
 	defer stmt.Close()
 	exec, execErr := stmt.Exec(
-       icao,
-       tailNumber,
-       firstSeen,
-       lastSeen,
-       msgCount,
-       emergency,
-       coordinates,
-       altitude,
-       groundSpeed,
-       headingTrack,
-       squawkCode,
-       verticalRate)
+		record.Icao,
+		record.TailNumber,
+		record.FirstSeen,
+		record.LastSeen,
+		record.MsgCount,
+		record.Emergency,
+		record.Location,
+		record.Altitude,
+		record.GroundSpeed,
+		record.HeadingTrack,
+		record.SquawkCode,
+		record.VerticalRate,
+		record.Receivers,
+		record.Callsign,
+		record.OnGround,
+		record.Ident,
+		record.SquawkChange,
+		record.Origin,
+		record.OriginRunway,
+		record.Destination,
+		record.DestinationRunway,
+		record.RejectedPoints)
 	if execErr != nil {
-		tx.Rollback()
 		return execErr
 	}
 	numRowsEffected, err := exec.RowsAffected()
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 	if numRowsEffected <= 0 {
-		tx.Rollback()
 		return errors.New(fmt.Sprintf("Expected to moodify more than 1 row but modified %d instead", numRowsEffected))
 	}
+	flightId, err := exec.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for _, insertChildren := range []func(tx *sql.Tx, flightId int64, record storage.FlightRecord) error{insertEvents, insertPositions, insertTelemetry} {
+		if err := insertChildren(tx, flightId, record); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// TODO cordinate are location, remove cordinate 
-func (d *Db) createTable() error {
-	d.mutex.Lock()
-	defer d.mutex.Unlock()
-	const CREATE_TABLE = `CREATE TABLE IF NOT EXISTS aircraftData (
-        "icao" VARCHAR(64),
-        "tailNumber" VARCHAR(64),
-        "firstSeen" UNSIGNED BIG INT, 
-        "lastSeen" UNSIGNED BIG INT, 
-        "msgCount" UNSIGNED BIG INT,
-        "emergency" BOOLEAN,
-        "cordinate" jsonb,
-        "location" jsonb,
-        "altitude" jsonb,
-        "groundSpeed" jsonb,
-        "headingTrack" jsonb,
-        "verticalRate" jsonb,
-        "squawkCode" jsonb
-        );
-        `
-
-	tx, dbbeginErr := d.databaseCon.Begin()
-	defer tx.Commit()
-	if dbbeginErr != nil {
-		return dbbeginErr
+// insertRows prepares statement once and runs it for each of count rows
+func insertRows(tx *sql.Tx, statement string, count int, args func(i int) []any) error {
+	if count == 0 {
+		return nil
 	}
-	exec, execErr := tx.Exec(CREATE_TABLE)
-	if execErr != nil {
-		tx.Rollback()
-		return execErr
+	stmt, prepErr := tx.Prepare(statement)
+	if prepErr != nil {
+		return prepErr
 	}
-	_, err := exec.RowsAffected()
-	if err != nil {
-		tx.Rollback()
-		return err
+	defer stmt.Close()
+	for i := 0; i < count; i++ {
+		if _, execErr := stmt.Exec(args(i)...); execErr != nil {
+			return execErr
+		}
 	}
 	return nil
 }
 
+func insertEvents(tx *sql.Tx, flightId int64, record storage.FlightRecord) error {
+	return insertRows(tx, `
+    insert into events(flight, icao, type, timestamp, lat, long, altitude, airport, runway)
+    values (?, ?, ?, ?, ?, ?, ?, ?, ?);
+    `, len(record.Events), func(i int) []any {
+		e := record.Events[i]
+		return []any{flightId, record.Icao, e.Type, e.TimestampUTC, e.Lat, e.Long, e.Altitude, e.Airport, e.Runway}
+	})
+}
+
+func insertPositions(tx *sql.Tx, flightId int64, record storage.FlightRecord) error {
+	return insertRows(tx, `
+    insert into positions(flight, icao, timestamp, lat, long, altitude, groundSpeed, headingTrack)
+    values (?, ?, ?, ?, ?, ?, ?, ?);
+    `, len(record.Positions), func(i int) []any {
+		p := record.Positions[i]
+		return []any{flightId, record.Icao, p.TimestampUTC, p.Lat, p.Long, p.Altitude, p.GroundSpeed, p.HeadingTrack}
+	})
+}
+
+func insertTelemetry(tx *sql.Tx, flightId int64, record storage.FlightRecord) error {
+	return insertRows(tx, `
+    insert into telemetry(flight, icao, timestamp, altitude, groundSpeed, headingTrack, verticalRate, squawkCode)
+    values (?, ?, ?, ?, ?, ?, ?, ?);
+    `, len(record.Telemetry), func(i int) []any {
+		t := record.Telemetry[i]
+		return []any{flightId, record.Icao, t.TimestampUTC, t.Altitude, t.GroundSpeed, t.HeadingTrack, t.VerticalRate, t.SquawkCode}
+	})
+}
+
 func (d *Db) TestConnnection() error {
 	return d.databaseCon.Ping()
 }
@@ -0,0 +1,20 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+func TestRetryable(t *testing.T) {
+	db := &Db{}
+	busy := fmt.Errorf("insert failed: %w", sqlite3.Error{Code: sqlite3.ErrBusy})
+	if db.Retryable(busy) == false || db.Retryable(sqlite3.Error{Code: sqlite3.ErrLocked}) == false {
+		t.Fatalf("Expected busy and locked to be retried")
+	}
+	if db.Retryable(sqlite3.Error{Code: sqlite3.ErrConstraint}) || db.Retryable(errors.New("no such table")) {
+		t.Fatalf("Expected constraint failures and other errors to not be retried")
+	}
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	sql "database/sql"
+	"math"
+	"sort"
+	"strings"
+
+	geo "github.com/kc8/dump-1090-aggergator/geo"
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+// likePattern escapes LIKE wildcards in txt and turns * into one
+func likePattern(txt string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return replacer.Replace(txt)
+}
+
+// FlightFilter narrows FindFlights, empty fields match everything
+type FlightFilter struct {
+	// ignores case and * matches anything so KLM* finds every KLM flight
+	Callsign string
+	// ident of the origin or destination Example: EHAM
+	Airport string
+}
+
+// FindFlights returns every flight that used the callsign at some point and came from or went to the
+// airport, oldest first
+func (d *Db) FindFlights(ctx context.Context, filter FlightFilter) ([]storage.FlightRecord, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	const FIND_FLIGHTS = `
+    select icao, tailNumber, firstSeen, lastSeen, msgCount, callsign, onGround,
+        origin, originRunway, destination, destinationRunway
+    from aircraftData
+    where (? = '' or exists (
+        select 1 from json_each(aircraftData.callsign)
+        where json_extract(json_each.value, '$.value') like ? escape '\'
+    ))
+    and (? = '' or upper(origin) = upper(?) or upper(destination) = upper(?))
+    order by firstSeen;
+    `
+	callsign := strings.TrimSpace(filter.Callsign)
+	airport := strings.TrimSpace(filter.Airport)
+	rows, queryErr := d.databaseCon.QueryContext(ctx, FIND_FLIGHTS, callsign, likePattern(callsign), airport, airport, airport)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+	result := make([]storage.FlightRecord, 0)
+	for rows.Next() {
+		var (
+			record                                               storage.FlightRecord
+			tailNumber                                           sql.NullString
+			origin, originRunway, destination, destinationRunway sql.NullString
+		)
+		if err := rows.Scan(&record.Icao, &tailNumber, &record.FirstSeen, &record.LastSeen, &record.MsgCount, &record.Callsign, &record.OnGround,
+			&origin, &originRunway, &destination, &destinationRunway); err != nil {
+			return nil, err
+		}
+		record.TailNumber = tailNumber.String
+		record.Origin = origin.String
+		record.OriginRunway = originRunway.String
+		record.Destination = destination.String
+		record.DestinationRunway = destinationRunway.String
+		result = append(result, record)
+	}
+	return result, rows.Err()
+}
+
+// CountEvents counts each type of event between from and until, UTC unix ms
+func (d *Db) CountEvents(ctx context.Context, from int64, until int64) (map[string]int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	rows, queryErr := d.databaseCon.QueryContext(ctx, `
+    select type, count(*) from events
+    where timestamp >= ? and timestamp < ?
+    group by type;
+    `, from, until)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+	result := make(map[string]int)
+	for rows.Next() {
+		var (
+			eventType string
+			count     int
+		)
+		if err := rows.Scan(&eventType, &count); err != nil {
+			return nil, err
+		}
+		result[eventType] = count
+	}
+	return result, rows.Err()
+}
+
+// NearbyFlight is the closest a flight came to a point
+type NearbyFlight struct {
+	Flight       int64 // id of the aircraftData row
+	Icao         string
+	TimestampUTC int64
+	Lat          float64
+	Long         float64
+	Altitude     sql.NullFloat64
+	DistanceKm   float64
+}
+
+// FindNear returns every flight with a position within radiusKm of lat long between from and until,
+// UTC unix ms, in the order they were closest. The indexes narrow it down to a box around the point
+// and the distance is worked out here
+func (d *Db) FindNear(ctx context.Context, lat float64, long float64, radiusKm float64, from int64, until int64) ([]NearbyFlight, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	dLat := radiusKm / (geo.EARTH_RADIUS_KM * math.Pi / 180)
+	minLong, maxLong := -180.0, 180.0
+	// near the poles or across the antimeridian the box is every longitude
+	if cos := math.Cos(lat * math.Pi / 180); cos > 0.01 {
+		dLong := dLat / cos
+		if long-dLong >= -180 && long+dLong <= 180 {
+			minLong, maxLong = long-dLong, long+dLong
+		}
+	}
+	rows, queryErr := d.databaseCon.QueryContext(ctx, `
+    select flight, icao, timestamp, lat, long, altitude from positions
+    where timestamp >= ? and timestamp < ?
+    and lat between ? and ? and long between ? and ?;
+    `, from, until, lat-dLat, lat+dLat, minLong, maxLong)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	defer rows.Close()
+	closest := make(map[int64]NearbyFlight)
+	for rows.Next() {
+		var p NearbyFlight
+		if err := rows.Scan(&p.Flight, &p.Icao, &p.TimestampUTC, &p.Lat, &p.Long, &p.Altitude); err != nil {
+			return nil, err
+		}
+		p.DistanceKm = geo.DistanceKm(lat, long, p.Lat, p.Long)
+		if p.DistanceKm > radiusKm {
+			continue
+		}
+		if best, ok := closest[p.Flight]; ok == false || p.DistanceKm < best.DistanceKm {
+			closest[p.Flight] = p
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	result := make([]NearbyFlight, 0, len(closest))
+	for _, p := range closest {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TimestampUTC < result[j].TimestampUTC
+	})
+	return result, nil
+}
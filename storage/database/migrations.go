@@ -0,0 +1,393 @@
+package database
+
+import (
+	"context"
+	sql "database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Migration moves the schema up one version. Databases made before versions were tracked may
+// already have some of a migrations changes so every Up has to be safe to run against them
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx) error
+}
+
+// MigrationStatus is a migration and when it was applied, AppliedAt is 0 while it is pending
+type MigrationStatus struct {
+	Migration
+	AppliedAt int64
+}
+
+func execAll(statements ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func addColumns(table string, columnType string, columns ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, column := range columns {
+			if err := addColumnIfMissing(tx, table, column, columnType); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// migrations in the order they are applied, only ever append to this list
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create aircraftData",
+		Up: execAll(`CREATE TABLE IF NOT EXISTS aircraftData (
+        "icao" VARCHAR(64),
+        "tailNumber" VARCHAR(64),
+        "firstSeen" UNSIGNED BIG INT,
+        "lastSeen" UNSIGNED BIG INT,
+        "msgCount" UNSIGNED BIG INT,
+        "emergency" BOOLEAN,
+        "cordinate" jsonb,
+        "location" jsonb,
+        "altitude" jsonb,
+        "groundSpeed" jsonb,
+        "headingTrack" jsonb,
+        "verticalRate" jsonb,
+        "squawkCode" jsonb
+        );`),
+	},
+	{
+		Version:     2,
+		Description: "add receivers, callsign and flag history to aircraftData",
+		Up:          addColumns(table_name, "jsonb", "receivers", "callsign", "onGround", "ident", "squawkChange"),
+	},
+	{
+		Version:     3,
+		Description: "create events",
+		// flight is the rowid of the aircraftData row the event happened in, which a VACUUM may
+		// renumber, migration 8 turns it into a reference to aircraftData.id
+		Up: execAll(`CREATE TABLE IF NOT EXISTS events (
+        "flight" INTEGER NOT NULL,
+        "icao" VARCHAR(64),
+        "type" VARCHAR(32),
+        "timestamp" UNSIGNED BIG INT,
+        "lat" REAL,
+        "long" REAL,
+        "altitude" REAL
+        );`,
+			`CREATE INDEX IF NOT EXISTS events_flight ON events (flight);`,
+			`CREATE INDEX IF NOT EXISTS events_timestamp ON events (timestamp, type);`),
+	},
+	{
+		Version:     4,
+		Description: "add origin and destination airports",
+		Up: func(tx *sql.Tx) error {
+			if err := addColumns(table_name, "VARCHAR(16)", "origin", "originRunway", "destination", "destinationRunway")(tx); err != nil {
+				return err
+			}
+			return addColumns("events", "VARCHAR(16)", "airport", "runway")(tx)
+		},
+	},
+	{
+		Version:     5,
+		Description: "add rejectedPoints to aircraftData",
+		Up:          addColumns(table_name, "INTEGER", "rejectedPoints"),
+	},
+	{
+		Version:     6,
+		Description: "drop the unused cordinate column, positions are in location",
+		Up: func(tx *sql.Tx) error {
+			exists, err := hasColumn(tx, table_name, "cordinate")
+			if err != nil || exists == false {
+				return err
+			}
+			_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN \"cordinate\";", table_name))
+			return err
+		},
+	},
+	{
+		Version:     7,
+		Description: "create positions and telemetry, copying positions out of location",
+		// like events, flight is the rowid of the aircraftData row until migration 8 makes it the id
+		Up: execAll(`CREATE TABLE IF NOT EXISTS positions (
+        "flight" INTEGER NOT NULL,
+        "icao" VARCHAR(64),
+        "timestamp" UNSIGNED BIG INT,
+        "lat" REAL,
+        "long" REAL,
+        "altitude" REAL,
+        "groundSpeed" REAL,
+        "headingTrack" INTEGER
+        );`,
+			`CREATE INDEX IF NOT EXISTS positions_flight ON positions (flight);`,
+			`CREATE INDEX IF NOT EXISTS positions_timestamp ON positions (timestamp);`,
+			`CREATE INDEX IF NOT EXISTS positions_lat_long ON positions (lat, long);`,
+			`CREATE TABLE IF NOT EXISTS telemetry (
+        "flight" INTEGER NOT NULL,
+        "icao" VARCHAR(64),
+        "timestamp" UNSIGNED BIG INT,
+        "altitude" REAL,
+        "groundSpeed" REAL,
+        "headingTrack" INTEGER,
+        "verticalRate" REAL,
+        "squawkCode" INTEGER
+        );`,
+			`CREATE INDEX IF NOT EXISTS telemetry_flight ON telemetry (flight, timestamp);`,
+			`CREATE INDEX IF NOT EXISTS telemetry_timestamp ON telemetry (timestamp);`,
+			`insert into positions(flight, icao, timestamp, lat, long)
+        select aircraftData.rowid, aircraftData.icao, json_extract(json_each.value, '$.timestamp'),
+            json_extract(json_each.value, '$.lat'), json_extract(json_each.value, '$.long')
+        from aircraftData, json_each(aircraftData.location)
+        where aircraftData.location is not null;`),
+	},
+	{
+		Version:     8,
+		Description: "give aircraftData an id primary key and point events, positions and telemetry at it",
+		// id is the rows old rowid so the flight columns already hold it, rows whose flight is gone
+		// can not be linked to anything and are not copied
+		Up: execAll(`CREATE TABLE aircraftData_new (
+        "id" INTEGER PRIMARY KEY,
+        "icao" VARCHAR(64),
+        "tailNumber" VARCHAR(64),
+        "firstSeen" UNSIGNED BIG INT,
+        "lastSeen" UNSIGNED BIG INT,
+        "msgCount" UNSIGNED BIG INT,
+        "emergency" BOOLEAN,
+        "location" jsonb,
+        "altitude" jsonb,
+        "groundSpeed" jsonb,
+        "headingTrack" jsonb,
+        "verticalRate" jsonb,
+        "squawkCode" jsonb,
+        "receivers" jsonb,
+        "callsign" jsonb,
+        "onGround" jsonb,
+        "ident" jsonb,
+        "squawkChange" jsonb,
+        "origin" VARCHAR(16),
+        "originRunway" VARCHAR(16),
+        "destination" VARCHAR(16),
+        "destinationRunway" VARCHAR(16),
+        "rejectedPoints" INTEGER
+        );`,
+			`insert into aircraftData_new
+        select rowid, icao, tailNumber, firstSeen, lastSeen, msgCount, emergency, location, altitude,
+            groundSpeed, headingTrack, verticalRate, squawkCode, receivers, callsign, onGround, ident,
+            squawkChange, origin, originRunway, destination, destinationRunway, rejectedPoints
+        from aircraftData;`,
+			`DROP TABLE aircraftData;`,
+			`ALTER TABLE aircraftData_new RENAME TO aircraftData;`,
+			`CREATE TABLE events_new (
+        "flight" INTEGER NOT NULL REFERENCES aircraftData ("id") ON DELETE CASCADE,
+        "icao" VARCHAR(64),
+        "type" VARCHAR(32),
+        "timestamp" UNSIGNED BIG INT,
+        "lat" REAL,
+        "long" REAL,
+        "altitude" REAL,
+        "airport" VARCHAR(16),
+        "runway" VARCHAR(16)
+        );`,
+			`insert into events_new
+        select flight, icao, type, timestamp, lat, long, altitude, airport, runway
+        from events where flight in (select id from aircraftData);`,
+			`DROP TABLE events;`,
+			`ALTER TABLE events_new RENAME TO events;`,
+			`CREATE INDEX events_flight ON events (flight);`,
+			`CREATE INDEX events_timestamp ON events (timestamp, type);`,
+			`CREATE TABLE positions_new (
+        "flight" INTEGER NOT NULL REFERENCES aircraftData ("id") ON DELETE CASCADE,
+        "icao" VARCHAR(64),
+        "timestamp" UNSIGNED BIG INT,
+        "lat" REAL,
+        "long" REAL,
+        "altitude" REAL,
+        "groundSpeed" REAL,
+        "headingTrack" INTEGER
+        );`,
+			`insert into positions_new
+        select flight, icao, timestamp, lat, long, altitude, groundSpeed, headingTrack
+        from positions where flight in (select id from aircraftData);`,
+			`DROP TABLE positions;`,
+			`ALTER TABLE positions_new RENAME TO positions;`,
+			`CREATE INDEX positions_flight ON positions (flight);`,
+			`CREATE INDEX positions_timestamp ON positions (timestamp);`,
+			`CREATE INDEX positions_lat_long ON positions (lat, long);`,
+			`CREATE TABLE telemetry_new (
+        "flight" INTEGER NOT NULL REFERENCES aircraftData ("id") ON DELETE CASCADE,
+        "icao" VARCHAR(64),
+        "timestamp" UNSIGNED BIG INT,
+        "altitude" REAL,
+        "groundSpeed" REAL,
+        "headingTrack" INTEGER,
+        "verticalRate" REAL,
+        "squawkCode" INTEGER
+        );`,
+			`insert into telemetry_new
+        select flight, icao, timestamp, altitude, groundSpeed, headingTrack, verticalRate, squawkCode
+        from telemetry where flight in (select id from aircraftData);`,
+			`DROP TABLE telemetry;`,
+			`ALTER TABLE telemetry_new RENAME TO telemetry;`,
+			`CREATE INDEX telemetry_flight ON telemetry (flight, timestamp);`,
+			`CREATE INDEX telemetry_timestamp ON telemetry (timestamp);`),
+	},
+}
+
+// LatestSchemaVersion is the version a database is at once every migration has run
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func (d *Db) createVersionTable(ctx context.Context) error {
+	_, err := d.databaseCon.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+        "version" INTEGER PRIMARY KEY,
+        "description" TEXT,
+        "appliedAt" UNSIGNED BIG INT
+        );`)
+	return err
+}
+
+// appliedMigrations maps each applied version to when it was applied, UTC unix ms
+func (d *Db) appliedMigrations(ctx context.Context) (map[int]int64, error) {
+	if err := d.createVersionTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := d.databaseCon.QueryContext(ctx, "select version, appliedAt from schema_version;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]int64)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt int64
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Migrations lists every migration this build knows about and whether it has been applied
+func (d *Db) Migrations(ctx context.Context) ([]MigrationStatus, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	applied, err := d.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		result = append(result, MigrationStatus{Migration: m, AppliedAt: applied[m.Version]})
+	}
+	return result, nil
+}
+
+// SchemaVersion is the highest migration applied, 0 for a database that has never been migrated
+func (d *Db) SchemaVersion(ctx context.Context) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	applied, err := d.appliedMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// MigrateTo applies every pending migration up to and including target, each in its own
+// transaction so a failure leaves the database at the last version that worked
+func (d *Db) MigrateTo(ctx context.Context, target int) ([]Migration, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	applied, err := d.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for v := range applied {
+		if v > LatestSchemaVersion() {
+			return nil, errors.New(fmt.Sprintf("Database is at schema version %d which is newer than this build knows, %d", v, LatestSchemaVersion()))
+		}
+	}
+	done := make([]Migration, 0)
+	for _, m := range migrations {
+		if m.Version > target {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		tx, beginErr := d.databaseCon.BeginTx(ctx, nil)
+		if beginErr != nil {
+			return done, beginErr
+		}
+		if upErr := m.Up(tx); upErr != nil {
+			tx.Rollback()
+			return done, errors.New(fmt.Sprintf("Migration %d %s failed: %s", m.Version, m.Description, upErr.Error()))
+		}
+		if _, err := tx.Exec("insert into schema_version(version, description, appliedAt) values (?, ?, ?);",
+			m.Version, m.Description, time.Now().UTC().UnixMilli()); err != nil {
+			tx.Rollback()
+			return done, err
+		}
+		if err := tx.Commit(); err != nil {
+			return done, err
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Migrate applies every pending migration
+func (d *Db) Migrate(ctx context.Context) ([]Migration, error) {
+	return d.MigrateTo(ctx, LatestSchemaVersion())
+}
+
+func hasColumn(tx *sql.Tx, table string, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s);", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid          int
+			name         string
+			ctype        string
+			notNull      int
+			defaultValue sql.NullString
+			primaryKey   int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &defaultValue, &primaryKey); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+func addColumnIfMissing(tx *sql.Tx, table string, column string, columnType string) error {
+	exists, err := hasColumn(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN \"%s\" %s;", table, column, columnType))
+	return err
+}
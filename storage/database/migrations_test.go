@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMigrateDatabaseFromBeforeVersions(t *testing.T) {
+	dir := t.TempDir()
+	old, err := Open("old.db", dir)
+	if err != nil {
+		t.Fatalf("Expected database got err %s", err.Error())
+	}
+	// the schema before migrations were tracked, with a flight already in it
+	if _, err := old.databaseCon.Exec(`CREATE TABLE aircraftData (
+        "icao" VARCHAR(64), "tailNumber" VARCHAR(64), "firstSeen" UNSIGNED BIG INT, "lastSeen" UNSIGNED BIG INT,
+        "msgCount" UNSIGNED BIG INT, "emergency" BOOLEAN, "cordinate" jsonb, "location" jsonb, "altitude" jsonb,
+        "groundSpeed" jsonb, "headingTrack" jsonb, "verticalRate" jsonb, "squawkCode" jsonb, "receivers" jsonb);
+        insert into aircraftData(icao, firstSeen, location) values ('4840D6', 1000, '[{"lat":52.31,"long":4.76,"timestamp":1000}]');`); err != nil {
+		t.Fatalf("Expected old schema got err %s", err.Error())
+	}
+	ctx := context.Background()
+	if version, _ := old.SchemaVersion(ctx); version != 0 {
+		t.Fatalf("Expected version 0 got %d", version)
+	}
+	applied, err := old.MigrateTo(ctx, 2)
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("Expected migrations 1 and 2 got %v %v", applied, err)
+	}
+	statuses, _ := old.Migrations(ctx)
+	if statuses[1].AppliedAt == 0 || statuses[2].AppliedAt != 0 {
+		t.Fatalf("Expected 2 applied and 3 pending got %v", statuses)
+	}
+	old.Clean()
+
+	db, err := New("old.db", dir)
+	if err != nil {
+		t.Fatalf("Expected New to migrate the rest got err %s", err.Error())
+	}
+	defer db.Clean()
+	if version, _ := db.SchemaVersion(ctx); version != LatestSchemaVersion() {
+		t.Fatalf("Expected version %d got %d", LatestSchemaVersion(), version)
+	}
+	tx, _ := db.databaseCon.Begin()
+	defer tx.Rollback()
+	for column, expected := range map[string]bool{"cordinate": false, "callsign": true, "rejectedPoints": true} {
+		if exists, _ := hasColumn(tx, table_name, column); exists != expected {
+			t.Fatalf("Expected column %s to exist %v", column, expected)
+		}
+	}
+	var lat float64
+	if err := tx.QueryRow("select lat from positions where icao = '4840D6';").Scan(&lat); err != nil || lat != 52.31 {
+		t.Fatalf("Expected the position to be copied out of location got %f %v", lat, err)
+	}
+	var icao string
+	if err := tx.QueryRow("select icao from aircraftData where firstSeen = 1000;").Scan(&icao); err != nil || icao != "4840D6" {
+		t.Fatalf("Expected the existing flight to survive got %s %v", icao, err)
+	}
+}
+
+func TestFlightIdsSurviveVacuum(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open("vacuum.db", dir)
+	if err != nil {
+		t.Fatalf("Expected database got err %s", err.Error())
+	}
+	defer db.Clean()
+	ctx := context.Background()
+	if _, err := db.MigrateTo(ctx, 7); err != nil {
+		t.Fatalf("Expected version 7 got err %s", err.Error())
+	}
+	// two flights linked by rowid as they were before version 8
+	if _, err := db.databaseCon.Exec(`insert into aircraftData(icao, firstSeen) values ('4840D6', 1000), ('A1B2C3', 2000);
+        insert into positions(flight, icao, lat, long) values (1, '4840D6', 52.31, 4.76), (2, 'A1B2C3', 40.5, -73.5);
+        insert into events(flight, icao, type, timestamp) values (2, 'A1B2C3', 'takeoff', 2000);`); err != nil {
+		t.Fatalf("Expected rows got err %s", err.Error())
+	}
+	if _, err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Expected the rest of the migrations got err %s", err.Error())
+	}
+	if _, err := db.databaseCon.Exec(`delete from aircraftData where icao = '4840D6'; VACUUM;`); err != nil {
+		t.Fatalf("Expected delete and vacuum got err %s", err.Error())
+	}
+	var (
+		icao  string
+		lat   float64
+		count int
+	)
+	if err := db.databaseCon.QueryRow(`select aircraftData.icao, positions.lat from positions
+        join aircraftData on aircraftData.id = positions.flight;`).Scan(&icao, &lat); err != nil || icao != "A1B2C3" || lat != 40.5 {
+		t.Fatalf("Expected A1B2C3 to keep its position got %s %f %v", icao, lat, err)
+	}
+	if err := db.databaseCon.QueryRow(`select aircraftData.icao from events
+        join aircraftData on aircraftData.id = events.flight;`).Scan(&icao); err != nil || icao != "A1B2C3" {
+		t.Fatalf("Expected the takeoff to stay with A1B2C3 got %s %v", icao, err)
+	}
+	if err := db.databaseCon.QueryRow(`select count(*) from positions;`).Scan(&count); err != nil || count != 1 {
+		t.Fatalf("Expected the deleted flights positions to go with it got %d %v", count, err)
+	}
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDriver records every statement it is sent and answers the queries this package makes, it
+// checks the order statements run in and not the SQL itself, that needs POSTGRES_TEST_URL
+type fakeDriver struct {
+	mutex      sync.Mutex
+	statements []string
+	postgis    bool
+	versions   []int64
+	failOn     string // any statement containing this fails
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+func (d *fakeDriver) record(statement string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.statements = append(d.statements, strings.TrimSpace(statement))
+	if d.failOn != "" && strings.Contains(statement, d.failOn) {
+		return errors.New("fake failure")
+	}
+	return nil
+}
+
+// ran is how many recorded statements contain part
+func (d *fakeDriver) ran(part string) int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	count := 0
+	for _, statement := range d.statements {
+		if strings.Contains(statement, part) {
+			count++
+		}
+	}
+	return count
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{driver: c.driver, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{driver: c.driver}, c.driver.record("BEGIN")
+}
+
+type fakeTx struct {
+	driver *fakeDriver
+}
+
+func (tx fakeTx) Commit() error {
+	return tx.driver.record("COMMIT")
+}
+
+func (tx fakeTx) Rollback() error {
+	return tx.driver.record("ROLLBACK")
+}
+
+type fakeStmt struct {
+	driver *fakeDriver
+	query  string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.driver.record(s.query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.driver.record(s.query); err != nil {
+		return nil, err
+	}
+	rows := &fakeRows{columns: []string{"value"}}
+	switch {
+	case strings.Contains(s.query, "pg_extension"):
+		rows.values = [][]driver.Value{{s.driver.postgis}}
+	case strings.Contains(s.query, "from schema_version"):
+		for _, v := range s.driver.versions {
+			rows.values = append(rows.values, []driver.Value{v})
+		}
+	case strings.Contains(s.query, "returning"):
+		rows.values = [][]driver.Value{{int64(1)}}
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	sql "database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// migration moves the schema up one version, like the sqlite ones only ever append to migrations
+type migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// every collector sharing the database takes this lock to migrate so only one applies each version
+const MIGRATION_LOCK = 1090
+
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "create flights, events, positions and telemetry",
+		// IF NOT EXISTS for databases created before versions were tracked
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS flights (
+        "id" BIGSERIAL PRIMARY KEY,
+        "icao" VARCHAR(64),
+        "tailNumber" VARCHAR(64),
+        "firstSeen" BIGINT,
+        "lastSeen" BIGINT,
+        "msgCount" BIGINT,
+        "emergency" INTEGER,
+        "location" jsonb,
+        "altitude" jsonb,
+        "groundSpeed" jsonb,
+        "headingTrack" jsonb,
+        "verticalRate" jsonb,
+        "squawkCode" jsonb,
+        "receivers" jsonb,
+        "callsign" jsonb,
+        "onGround" jsonb,
+        "ident" jsonb,
+        "squawkChange" jsonb,
+        "origin" VARCHAR(16),
+        "originRunway" VARCHAR(8),
+        "destination" VARCHAR(16),
+        "destinationRunway" VARCHAR(8),
+        "rejectedPoints" INTEGER,
+        "track" geometry(LineString, 4326)
+        );`,
+			`CREATE INDEX IF NOT EXISTS flights_icao ON flights ("icao", "firstSeen");`,
+			`CREATE INDEX IF NOT EXISTS flights_track ON flights USING GIST ("track");`,
+			`CREATE TABLE IF NOT EXISTS events (
+        "flight" BIGINT NOT NULL REFERENCES flights ("id") ON DELETE CASCADE,
+        "icao" VARCHAR(64),
+        "type" VARCHAR(32),
+        "timestamp" BIGINT,
+        "lat" DOUBLE PRECISION,
+        "long" DOUBLE PRECISION,
+        "altitude" DOUBLE PRECISION,
+        "airport" VARCHAR(16),
+        "runway" VARCHAR(8)
+        );`,
+			`CREATE INDEX IF NOT EXISTS events_flight ON events ("flight");`,
+			`CREATE INDEX IF NOT EXISTS events_timestamp ON events ("timestamp", "type");`,
+			`CREATE TABLE IF NOT EXISTS positions (
+        "flight" BIGINT NOT NULL REFERENCES flights ("id") ON DELETE CASCADE,
+        "icao" VARCHAR(64),
+        "timestamp" BIGINT,
+        "lat" DOUBLE PRECISION,
+        "long" DOUBLE PRECISION,
+        "altitude" DOUBLE PRECISION,
+        "groundSpeed" DOUBLE PRECISION,
+        "headingTrack" INTEGER,
+        "geom" geometry(Point, 4326)
+        );`,
+			`CREATE INDEX IF NOT EXISTS positions_flight ON positions ("flight");`,
+			`CREATE INDEX IF NOT EXISTS positions_timestamp ON positions ("timestamp");`,
+			`CREATE INDEX IF NOT EXISTS positions_geom ON positions USING GIST ("geom");`,
+			`CREATE TABLE IF NOT EXISTS telemetry (
+        "flight" BIGINT NOT NULL REFERENCES flights ("id") ON DELETE CASCADE,
+        "icao" VARCHAR(64),
+        "timestamp" BIGINT,
+        "altitude" DOUBLE PRECISION,
+        "groundSpeed" DOUBLE PRECISION,
+        "headingTrack" INTEGER,
+        "verticalRate" DOUBLE PRECISION,
+        "squawkCode" INTEGER
+        );`,
+			`CREATE INDEX IF NOT EXISTS telemetry_flight ON telemetry ("flight", "timestamp");`,
+			`CREATE INDEX IF NOT EXISTS telemetry_timestamp ON telemetry ("timestamp");`,
+		},
+	},
+}
+
+// requirePostgis checks rather than creates the extension, creating it needs a superuser and the
+// collector should not
+func requirePostgis(ctx context.Context, db *sql.DB) error {
+	var installed bool
+	if err := db.QueryRowContext(ctx, `select exists(select 1 from pg_extension where extname = 'postgis');`).Scan(&installed); err != nil {
+		return err
+	}
+	if installed == false {
+		return errors.New("PostGIS is not installed in this database, have a superuser run CREATE EXTENSION postgis; in it first")
+	}
+	return nil
+}
+
+// migrate applies every pending migration in one transaction, postgres rolls the schema back with it
+func migrate(ctx context.Context, db *sql.DB) ([]migration, error) {
+	tx, beginErr := db.BeginTx(ctx, nil)
+	if beginErr != nil {
+		return nil, beginErr
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `select pg_advisory_xact_lock($1);`, MIGRATION_LOCK); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (
+        "version" INTEGER PRIMARY KEY,
+        "description" TEXT,
+        "appliedAt" BIGINT
+        );`); err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, `select "version" from schema_version;`)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	latest := migrations[len(migrations)-1].Version
+	for v := range applied {
+		if v > latest {
+			return nil, errors.New(fmt.Sprintf("Database is at schema version %d which is newer than this build knows, %d", v, latest))
+		}
+	}
+	done := make([]migration, 0)
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		for _, statement := range m.Statements {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				return nil, errors.New(fmt.Sprintf("Migration %d %s failed: %s", m.Version, m.Description, err.Error()))
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `insert into schema_version("version", "description", "appliedAt") values ($1, $2, $3);`,
+			m.Version, m.Description, time.Now().UTC().UnixMilli()); err != nil {
+			return nil, err
+		}
+		done = append(done, m)
+	}
+	return done, tx.Commit()
+}
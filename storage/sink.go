@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	sql "database/sql"
+)
+
+// FlightSink is where finished flights are written, sqlite in storage/database and postgres in
+// storage/postgres
+type FlightSink interface {
+	Insert(ctx context.Context, record FlightRecord) error
+	// InsertBatch writes every record in one transaction, all or none of them
+	InsertBatch(ctx context.Context, records []FlightRecord) error
+	// Retryable is true for errors that go away on their own, a busy database or a dropped connection
+	Retryable(err error) bool
+	Clean() error
+}
+
+// FlightRecord is one finished flight, every series is json. Events, Positions and Telemetry are
+// stored as rows of their own and the json series are left null when they are nil
+type FlightRecord struct {
+	Icao         string
+	TailNumber   string
+	FirstSeen    int64
+	LastSeen     int64
+	MsgCount     uint64
+	Emergency    int
+	Location     []byte
+	Altitude     []byte
+	GroundSpeed  []byte
+	HeadingTrack []byte
+	VerticalRate []byte
+	SquawkCode   []byte
+	Receivers    []byte
+	Callsign     []byte
+	OnGround     []byte
+	Ident        []byte
+	SquawkChange []byte
+	// nearest airport idents, empty when unknown
+	Origin            string
+	OriginRunway      string
+	Destination       string
+	DestinationRunway string
+	// positions and altitudes dropped as implausible
+	RejectedPoints int
+	Events         []Event
+	Positions      []Position
+	Telemetry      []Telemetry
+}
+
+// Event is a takeoff, landing, touch and go or go around, stored against the flight
+type Event struct {
+	Type         string
+	TimestampUTC int64
+	Lat          sql.NullFloat64
+	Long         sql.NullFloat64
+	Altitude     sql.NullFloat64
+	Airport      string
+	Runway       string
+}
+
+// Position is one point of the track with the telemetry in effect when it was reported
+type Position struct {
+	TimestampUTC int64
+	Lat          float64
+	Long         float64
+	Altitude     sql.NullFloat64
+	GroundSpeed  sql.NullFloat64
+	HeadingTrack sql.NullInt64
+}
+
+// Telemetry is every value known at a moment one of them changed
+type Telemetry struct {
+	TimestampUTC int64
+	Altitude     sql.NullFloat64
+	GroundSpeed  sql.NullFloat64
+	HeadingTrack sql.NullInt64
+	VerticalRate sql.NullFloat64
+	SquawkCode   sql.NullInt64
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+const (
+	RECONNECT_MIN_BACKOFF = time.Second
+	RECONNECT_MAX_BACKOFF = 2 * time.Minute
+	// a connection that stayed up this long was healthy, the next failure starts backing off from the minimum again
+	RECONNECT_HEALTHY_AFTER   = 30 * time.Second
+	DEFAULT_READ_IDLE_TIMEOUT = 60 * time.Second
+)
+
+// a connection that has not delivered anything for this long is dropped and redialed
+var readIdleTimeout = DEFAULT_READ_IDLE_TIMEOUT
+
+type connectionState int
+
+const (
+	CONNECTING connectionState = iota
+	CONNECTED
+	DISCONNECTED
+)
+
+func (s connectionState) String() string {
+	switch s {
+	case CONNECTING:
+		return "connecting"
+	case CONNECTED:
+		return "connected"
+	case DISCONNECTED:
+		return "disconnected"
+	}
+	return "unknown"
+}
+
+// backoff doubles from min up to max, each delay is jittered down by up to half so
+// several receivers behind the same router do not all redial at once
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	attempt int
+}
+
+func (b *backoff) next() time.Duration {
+	delay := b.max
+	if b.attempt < 32 && b.min<<b.attempt < b.max {
+		delay = b.min << b.attempt
+	}
+	b.attempt++
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int64N(half+1))
+}
+
+func (b *backoff) reset() {
+	b.attempt = 0
+}
+
+// idleConn drops connections that stop sending, every read pushes the deadline back
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c idleConn) Read(p []byte) (int, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(p)
+}
+
+// superviseConnection dials rcv and hands the connection to consume until it fails, then redials
+// with backoff, it only returns once ctx is done
+func superviseConnection(ctx context.Context, rcv receiver, consume func(conn net.Conn) error) {
+	retry := backoff{min: RECONNECT_MIN_BACKOFF, max: RECONNECT_MAX_BACKOFF}
+	attempts := 0
+	var downSince time.Time
+	for ctx.Err() == nil {
+		attempts++
+		Log(fmt.Sprintf("Receiver %s %s, attempt %d", rcv, CONNECTING, attempts), INFO)
+		conn, dialErr := generateConnection(ctx, rcv.Host, rcv.Port)
+		if dialErr != nil {
+			wait := retry.next()
+			Log(fmt.Sprintf("Failed to dial %s due to %s, retrying in %s", rcv, dialErr.Error(), wait.Round(time.Millisecond)), WARN)
+			sleepContext(ctx, wait)
+			continue
+		}
+		if downSince.IsZero() {
+			Log(fmt.Sprintf("Receiver %s %s", rcv, CONNECTED), INFO)
+		} else {
+			Log(fmt.Sprintf("Receiver %s %s after %d attempts, down for %s", rcv, CONNECTED, attempts, time.Since(downSince).Round(time.Second)), INFO)
+		}
+		attempts = 0
+		connectedAt := time.Now()
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		readErr := consume(idleConn{Conn: conn, timeout: readIdleTimeout})
+		stop()
+		conn.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		downSince = time.Now()
+		if time.Since(connectedAt) >= RECONNECT_HEALTHY_AFTER {
+			retry.reset()
+		}
+		wait := retry.next()
+		Log(fmt.Sprintf("Receiver %s %s due to %s, redialing in %s", rcv, DISCONNECTED, readErr.Error(), wait.Round(time.Millisecond)), WARN)
+		sleepContext(ctx, wait)
+	}
+}
+
+func sleepContext(ctx context.Context, wait time.Duration) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBackoffIsCappedAndJittered(t *testing.T) {
+	b := backoff{min: time.Second, max: 8 * time.Second}
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second} {
+		wait := b.next()
+		if wait < expected/2 || wait > expected {
+			t.Fatalf("Expected attempt %d to wait between %s and %s got %s", i, expected/2, expected, wait)
+		}
+	}
+	b.reset()
+	if wait := b.next(); wait > time.Second {
+		t.Fatalf("Expected reset to start from the minimum got %s", wait)
+	}
+}
+
+func TestSuperviseConnectionRedials(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected to listen got err %s", err.Error())
+	}
+	defer ln.Close()
+	accepted := make(chan bool, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// the piaware rebooting
+			conn.Close()
+			accepted <- true
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	exited := make(chan bool)
+	go func() {
+		superviseConnection(ctx, receiver{Id: "north", Host: host, Port: port, Format: "sbs"}, func(conn net.Conn) error {
+			_, err := conn.Read(make([]byte, 1))
+			return err
+		})
+		exited <- true
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-accepted:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected connection %d to be dialed", i+1)
+		}
+	}
+	cancel()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected supervisor to exit once cancelled")
+	}
+}
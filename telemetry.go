@@ -0,0 +1,128 @@
+package main
+
+import (
+	"cmp"
+	sql "database/sql"
+	"flag"
+	"slices"
+	"sort"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+// also write the series as json on the flight row, for anything still reading those columns
+var storeJson = true
+
+func addStorageFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&storeJson, "storeJson", storeJson, "Also store the position and telemetry series as json on the flight row, they are always in the positions and telemetry tables")
+}
+
+// inTimeOrder sorts a copy of series, messages from several receivers are appended in the order
+// they arrived which is not always the order they were sent. Once sorted a value can follow the
+// same value, only the first is kept so series still only hold changes
+func inTimeOrder[T int | float32 | string | bool](series []DataOverTime[T]) []DataOverTime[T] {
+	sorted := slices.Clone(series)
+	slices.SortStableFunc(sorted, func(a DataOverTime[T], b DataOverTime[T]) int {
+		return cmp.Compare(a.TimestampUTC, b.TimestampUTC)
+	})
+	return slices.CompactFunc(sorted, func(a DataOverTime[T], b DataOverTime[T]) bool {
+		return a.Data == b.Data
+	})
+}
+
+// timeOrdered is data with every series in time order, everything worked out from a flight
+// expects this
+func timeOrdered(data CollectedData) CollectedData {
+	data.Coordinates = slices.Clone(data.Coordinates)
+	slices.SortStableFunc(data.Coordinates, func(a CordinatesOverTime, b CordinatesOverTime) int {
+		return cmp.Compare(a.TimestampUTC, b.TimestampUTC)
+	})
+	data.Altitude = inTimeOrder(data.Altitude)
+	data.GroundSpeed = inTimeOrder(data.GroundSpeed)
+	data.HeadingTrack = inTimeOrder(data.HeadingTrack)
+	data.VerticalRate = inTimeOrder(data.VerticalRate)
+	data.SquawkCode = inTimeOrder(data.SquawkCode)
+	data.Callsign = inTimeOrder(data.Callsign)
+	data.OnGround = inTimeOrder(data.OnGround)
+	data.Ident = inTimeOrder(data.Ident)
+	data.SquawkChange = inTimeOrder(data.SquawkChange)
+	return data
+}
+
+// seriesCursor walks a series forward in time, the series must be in time order, see timeOrdered
+type seriesCursor[T int | float32] struct {
+	series []DataOverTime[T]
+	next   int
+	value  Nullable[T]
+}
+
+// at is the latest value at or before timestamp, timestamps have to be passed in increasing order
+func (c *seriesCursor[T]) at(timestamp int64) Nullable[T] {
+	for c.next < len(c.series) && c.series[c.next].TimestampUTC <= timestamp {
+		c.value = Nullable[T]{Value: c.series[c.next].Data, Valid: true}
+		c.next++
+	}
+	return c.value
+}
+
+func toNullInt(value Nullable[int]) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(value.Value), Valid: value.Valid}
+}
+
+// toDatabasePositions is every position with the altitude, speed and track in effect at it
+func toDatabasePositions(data CollectedData) []storage.Position {
+	altitude := seriesCursor[float32]{series: data.Altitude}
+	speed := seriesCursor[float32]{series: data.GroundSpeed}
+	track := seriesCursor[int]{series: data.HeadingTrack}
+	result := make([]storage.Position, 0, len(data.Coordinates))
+	for _, c := range data.Coordinates {
+		result = append(result, storage.Position{
+			TimestampUTC: c.TimestampUTC,
+			Lat:          float64(c.Lat),
+			Long:         float64(c.Long),
+			Altitude:     toNullFloat(altitude.at(c.TimestampUTC)),
+			GroundSpeed:  toNullFloat(speed.at(c.TimestampUTC)),
+			HeadingTrack: toNullInt(track.at(c.TimestampUTC)),
+		})
+	}
+	return result
+}
+
+func appendTimestamps[T int | float32](timestamps []int64, series []DataOverTime[T]) []int64 {
+	for _, point := range series {
+		timestamps = append(timestamps, point.TimestampUTC)
+	}
+	return timestamps
+}
+
+// toDatabaseTelemetry has a row for each moment any of the numeric series changed, with every
+// value known at that moment
+func toDatabaseTelemetry(data CollectedData) []storage.Telemetry {
+	timestamps := appendTimestamps(nil, data.Altitude)
+	timestamps = appendTimestamps(timestamps, data.GroundSpeed)
+	timestamps = appendTimestamps(timestamps, data.HeadingTrack)
+	timestamps = appendTimestamps(timestamps, data.VerticalRate)
+	timestamps = appendTimestamps(timestamps, data.SquawkCode)
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	altitude := seriesCursor[float32]{series: data.Altitude}
+	speed := seriesCursor[float32]{series: data.GroundSpeed}
+	track := seriesCursor[int]{series: data.HeadingTrack}
+	verticalRate := seriesCursor[float32]{series: data.VerticalRate}
+	squawk := seriesCursor[int]{series: data.SquawkCode}
+	result := make([]storage.Telemetry, 0, len(timestamps))
+	for i, ts := range timestamps {
+		if i > 0 && timestamps[i-1] == ts {
+			continue
+		}
+		result = append(result, storage.Telemetry{
+			TimestampUTC: ts,
+			Altitude:     toNullFloat(altitude.at(ts)),
+			GroundSpeed:  toNullFloat(speed.at(ts)),
+			HeadingTrack: toNullInt(track.at(ts)),
+			VerticalRate: toNullFloat(verticalRate.at(ts)),
+			SquawkCode:   toNullInt(squawk.at(ts)),
+		})
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+	database "github.com/kc8/dump-1090-aggergator/storage/database"
+)
+
+func TestToDatabaseTelemetry(t *testing.T) {
+	data := CollectedData{
+		Altitude:   series(DataOverTime[float32]{Data: 1000, TimestampUTC: 10}, DataOverTime[float32]{Data: 2000, TimestampUTC: 30}),
+		SquawkCode: series(DataOverTime[int]{Data: 7000, TimestampUTC: 20}, DataOverTime[int]{Data: 7001, TimestampUTC: 30}),
+	}
+	rows := toDatabaseTelemetry(data)
+	if len(rows) != 3 {
+		t.Fatalf("Expected a row at 10 20 and 30 got %v", rows)
+	}
+	if rows[0].SquawkCode.Valid || rows[1].Altitude.Float64 != 1000 || rows[1].SquawkCode.Int64 != 7000 {
+		t.Fatalf("Expected values to carry forward got %v", rows)
+	}
+	if rows[2].Altitude.Float64 != 2000 || rows[2].SquawkCode.Int64 != 7001 || rows[2].GroundSpeed.Valid {
+		t.Fatalf("Expected 2000 7001 and no speed at 30 got %v", rows[2])
+	}
+}
+
+func TestFindNear(t *testing.T) {
+	db, err := database.New("near.db", t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected database got err %s", err.Error())
+	}
+	defer db.Clean()
+	storeJson = false
+	defer func() { storeJson = true }()
+
+	// one flight over Schiphol at 1000ms and one over Lelystad at 2000ms
+	for i, c := range []CordinatesOverTime{{Lat: 52.31, Long: 4.76, TimestampUTC: 1000}, {Lat: 52.46, Long: 5.53, TimestampUTC: 2000}} {
+		data := CollectedData{Icao: []string{"4840D6", "A1B2C3"}[i], Coordinates: []CordinatesOverTime{c}}
+		if err := insertEntry(context.Background(), db, storage.MapItem[CollectedData]{Key: data.Icao, Data: data}); err != nil {
+			t.Fatalf("Expected insert got err %s", err.Error())
+		}
+	}
+	flights, err := db.FindNear(context.Background(), 52.30, 4.76, 5, 0, 10_000)
+	if err != nil || len(flights) != 1 || flights[0].Icao != "4840D6" || flights[0].DistanceKm > 2 {
+		t.Fatalf("Expected only 4840D6 near Schiphol got %v %v", flights, err)
+	}
+	if flights, _ := db.FindNear(context.Background(), 52.30, 4.76, 100, 1500, 10_000); len(flights) != 1 || flights[0].Icao != "A1B2C3" {
+		t.Fatalf("Expected only A1B2C3 after 1500 got %v", flights)
+	}
+	if found, _ := db.FindFlights(context.Background(), database.FlightFilter{}); len(found) != 2 {
+		t.Fatalf("Expected both flights got %v", found)
+	}
+}
+
+func TestToDatabasePositionsOutOfOrder(t *testing.T) {
+	// a lagging receiver delivered the climb to 2000 after the position at 40
+	data := CollectedData{
+		Coordinates: []CordinatesOverTime{{Lat: 52.31, Long: 4.76, TimestampUTC: 40}, {Lat: 52.30, Long: 4.75, TimestampUTC: 20}},
+		Altitude:    series(DataOverTime[float32]{Data: 1000, TimestampUTC: 10}, DataOverTime[float32]{Data: 3000, TimestampUTC: 50}, DataOverTime[float32]{Data: 2000, TimestampUTC: 30}),
+	}
+	data = timeOrdered(data)
+	positions := toDatabasePositions(data)
+	if len(positions) != 2 || positions[0].TimestampUTC != 20 || positions[0].Altitude.Float64 != 1000 || positions[1].Altitude.Float64 != 2000 {
+		t.Fatalf("Expected 1000 at 20 and 2000 at 40 got %v", positions)
+	}
+	rows := toDatabaseTelemetry(data)
+	if len(rows) != 3 || rows[1].TimestampUTC != 30 || rows[1].Altitude.Float64 != 2000 || rows[2].Altitude.Float64 != 3000 {
+		t.Fatalf("Expected 2000 at 30 and 3000 at 50 got %v", rows)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	TIME_SOURCE_GENERATED = "generated"
+	TIME_SOURCE_LOGGED    = "logged"
+	TIME_SOURCE_LOCAL     = "local"
+)
+
+var (
+	// which timestamp aggregation stamps a message with, local is the collectors own clock
+	timeSource = TIME_SOURCE_LOCAL
+	// SBS dates and times are written in the receivers local time without a zone
+	receiverTimezone = time.Local
+	clock            = messageClock{}
+)
+
+// messageClock follows the newest message time so the scanner ages aircraft out on the
+// same clock they were stamped with, time keeps passing between messages so a feed that
+// goes quiet still gets flushed
+type messageClock struct {
+	mu     sync.Mutex
+	latest int64
+	seenAt int64
+}
+
+func (c *messageClock) observe(timestamp int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if timestamp > c.latest {
+		c.latest = timestamp
+		c.seenAt = time.Now().UTC().UnixMilli()
+	}
+}
+
+func (c *messageClock) now() int64 {
+	wall := time.Now().UTC().UnixMilli()
+	if timeSource == TIME_SOURCE_LOCAL {
+		return wall
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.latest == 0 {
+		return wall
+	}
+	return c.latest + (wall - c.seenAt)
+}
+
+func parseTimeSource(txt string) (string, error) {
+	switch txt {
+	case TIME_SOURCE_GENERATED, TIME_SOURCE_LOGGED, TIME_SOURCE_LOCAL:
+		return txt, nil
+	}
+	return "", errors.New(fmt.Sprintf("Unknown time source %s expected generated, logged or local", txt))
+}
+
+func setTimeSource(source string, timezone string) {
+	parsed, sourceErr := parseTimeSource(source)
+	if sourceErr != nil {
+		Log(sourceErr.Error(), FATAL)
+	}
+	location, tzErr := time.LoadLocation(timezone)
+	if tzErr != nil {
+		Log(fmt.Sprintf("Unknown receiver timezone %s: %s", timezone, tzErr.Error()), FATAL)
+	}
+	timeSource = parsed
+	receiverTimezone = location
+}
+
+// messageTimestamp is the UTC unix ms a message is aggregated at, messages without
+// the chosen timestamp fall back to the local clock
+func messageTimestamp(msg *FormattedAdbsMsg) int64 {
+	var timestamp time.Time
+	switch timeSource {
+	case TIME_SOURCE_GENERATED:
+		timestamp = msg.GeneratedTimestamp
+	case TIME_SOURCE_LOGGED:
+		timestamp = msg.LoggedTimestamp
+	}
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	result := timestamp.UTC().UnixMilli()
+	clock.observe(result)
+	return result
+}
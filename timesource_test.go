@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessageTimestampSources(t *testing.T) {
+	defer func(source string, tz *time.Location) { timeSource, receiverTimezone = source, tz }(timeSource, receiverTimezone)
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("No timezone data %s", err.Error())
+	}
+	receiverTimezone = newYork
+	// generated at 08:00 EDT and logged two seconds later
+	msg, err := ParseCSVFormat([]byte("MSG,3,1,1,A1B2C3,1,2024/10/08,08:00:00.000,2024/10/08,08:00:02.000,,35000,,,40.50000,-73.50000,,,0,0,0,0"))
+	if err != nil {
+		t.Fatalf("Expected message got err %s", err.Error())
+	}
+
+	timeSource = TIME_SOURCE_GENERATED
+	if ts := messageTimestamp(msg); ts != 1728388800000 {
+		t.Fatalf("Expected generated time 12:00 UTC got %d", ts)
+	}
+	timeSource = TIME_SOURCE_LOGGED
+	if ts := messageTimestamp(msg); ts != 1728388802000 {
+		t.Fatalf("Expected logged time 12:00:02 UTC got %d", ts)
+	}
+	timeSource = TIME_SOURCE_LOCAL
+	before := time.Now().UTC().UnixMilli()
+	if ts := messageTimestamp(msg); ts < before {
+		t.Fatalf("Expected the local clock got %d", ts)
+	}
+}
+
+func TestMessageClockFollowsMessages(t *testing.T) {
+	defer func(source string) { timeSource = source }(timeSource)
+	timeSource = TIME_SOURCE_GENERATED
+	c := messageClock{}
+	c.observe(1728388800000)
+	c.observe(1728388700000) // late messages do not move the clock back
+	now := c.now()
+	if now < 1728388800000 || now > 1728388801000 {
+		t.Fatalf("Expected the clock to follow the newest message got %d", now)
+	}
+	if _, err := parseTimeSource("gps"); err == nil {
+		t.Fatalf("Expected unknown time source to fail")
+	}
+}
@@ -25,7 +25,7 @@ func (c *CordinatesOverTime) Value() ([]byte, error) {
 	return b, err
 }
 
-type DataOverTime[T int | float32] struct {
+type DataOverTime[T int | float32 | string | bool] struct {
 	Data         T     `json:"value"`
 	TimestampUTC int64 `json:"timestamp"`
 }
@@ -53,10 +53,26 @@ type CollectedData struct {
 	HeadingTrack []DataOverTime[int]     `json:"headingTrack"`
 	VerticalRate []DataOverTime[float32] `json:"verticalRate"`
 	SquawkCode   []DataOverTime[int]     `json:"squawkCode"`
+	Callsign     []DataOverTime[string]  `json:"callsign"`
+	// flags only get a new entry when they change, so OnGround is the list of ground and air transitions
+	OnGround     []DataOverTime[bool]    `json:"onGround"`
+	Ident        []DataOverTime[bool]    `json:"ident"`        // SPI, the pilot pressed ident
+	SquawkChange []DataOverTime[bool]    `json:"squawkChange"` // alert, the squawk was changed
 	Emergency    Nullable[int]
+	Receivers    []string `json:"receivers"` // ids of every receiver that heard the aircraft
+	Lost         bool     `json:"lost"`      // a receiver sent STA saying it stopped tracking the aircraft
+	Finished     bool     `json:"finished"`  // split off from the aircraft as a completed flight
+	// positions and altitudes dropped as implausible, and how many in a row so a bad first fix does not stick
+	RejectedPoints    int `json:"rejectedPoints"`
+	PositionRejectRun int `json:"positionRejectRun"`
+	AltitudeRejectRun int `json:"altitudeRejectRun"`
+
+	// latest half of each CPR pair, used to resolve positions from raw feeds
+	EvenCPR Nullable[CPROverTime]
+	OddCPR  Nullable[CPROverTime]
 }
 
-func convertDataOverTimeToJson[T float32 | int](data []DataOverTime[T]) ([]byte, error) {
+func convertDataOverTimeToJson[T float32 | int | string | bool](data []DataOverTime[T]) ([]byte, error) {
 	b, err := json.Marshal(data)
 	return b, err
 }
@@ -65,3 +81,11 @@ func convertCordinatesOverTimeToJson(data []CordinatesOverTime) ([]byte, error)
 	b, err := json.Marshal(data)
 	return b, err
 }
+
+func convertReceiversToJson(data []string) ([]byte, error) {
+	if data == nil {
+		data = []string{}
+	}
+	b, err := json.Marshal(data)
+	return b, err
+}
@@ -15,6 +15,8 @@ const (
 	SEARCH        = 3
 	CLEAN         = 4
 	UPDATE_OR_ADD = 5
+	SYNC          = 6
+	STOP          = 7
 )
 
 type Task struct {
@@ -23,6 +25,7 @@ type Task struct {
 	key      string
 	taskType TaskType
 	visitFn  storage.DoPerEntry[CollectedData]
+	synced   chan bool
 }
 
 type modifyStoQueue struct {
@@ -79,6 +82,31 @@ func (q *modifyStoQueue) updateOrAdd(raw *FormattedAdbsMsg) {
 	q.queue.Enqueue(task)
 }
 
+// sync blocks until every task queued before it has run
+func (q *modifyStoQueue) sync() {
+	task := Task{
+		taskType: SYNC,
+		synced:   make(chan bool),
+	}
+	q.queue.Enqueue(task)
+	<-task.synced
+}
+
+// stop ends run once the tasks queued before it have run
+func (q *modifyStoQueue) stop() {
+	q.queue.Enqueue(Task{taskType: STOP})
+}
+
+// snapshot copies every entry once the tasks queued before it have run
+func (q *modifyStoQueue) snapshot() []storage.MapItem[CollectedData] {
+	items := make([]storage.MapItem[CollectedData], 0)
+	q.checkForReadyToDelete(func(item storage.MapItem[CollectedData]) {
+		items = append(items, item)
+	})
+	q.sync()
+	return items
+}
+
 func (q *modifyStoQueue) run(fndChan chan Nullable[storage.MapItem[CollectedData]]) {
 	arr := make([]string, 0)
 	for {
@@ -95,21 +123,20 @@ func (q *modifyStoQueue) run(fndChan chan Nullable[storage.MapItem[CollectedData
 			}
 			Log(currentTask.item.Data.Icao, ERROR)
 			arr = append(arr, currentTask.item.Data.Icao)
-			if err := sto.Insert(currentTask.item, simpleKeyCompare); err != nil {
+			if err := q.backendSto.Insert(currentTask.item, simpleKeyCompare); err != nil {
 				Log(err.Error(), ERROR)
 			}
 		}
 		if currentTask.taskType == UPDATE_OR_ADD {
 			foundItem, findErr := q.backendSto.Search(currentTask.key, simpleKeyCompare)
-			if findErr != nil { // okay to add
-				sto.Insert(createNewDataEntry(currentTask.raw), simpleKeyCompare)
-			} else { // update
-				sto.Insert(updateEntry(foundItem, currentTask.raw), simpleKeyCompare)
+			now := messageTimestamp(currentTask.raw)
+			for _, item := range aggregate(foundItem, findErr == nil, currentTask.raw, now) {
+				q.backendSto.Insert(item, simpleKeyCompare)
 			}
 		}
 		if currentTask.taskType == DELETE {
 			nodeKey := currentTask.item.Key
-			if _, delErr := sto.Delete(currentTask.item.Key, simpleKeyCompare); delErr != nil {
+			if _, delErr := q.backendSto.Delete(currentTask.item.Key, simpleKeyCompare); delErr != nil {
 				Log(fmt.Sprintf("Could not remove entry from storage due to one of the following."+
 					"(1) Errmsg: %s (2): item key %s", delErr.Error(), nodeKey), ERROR)
 			} else {
@@ -118,7 +145,13 @@ func (q *modifyStoQueue) run(fndChan chan Nullable[storage.MapItem[CollectedData
 			}
 		}
 		if currentTask.taskType == CLEAN {
-			sto.Traverse(currentTask.visitFn)
+			q.backendSto.Traverse(currentTask.visitFn)
+		}
+		if currentTask.taskType == SYNC {
+			close(currentTask.synced)
+		}
+		if currentTask.taskType == STOP {
+			return
 		}
 		if currentTask.taskType == SEARCH {
 			foundItem, findErr := q.backendSto.Search(currentTask.key, simpleKeyCompare)
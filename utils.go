@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"log"
-	"math"
 	"net/http"
 )
 
@@ -86,13 +85,3 @@ func simpleKeyCompare(a int, b int) int {
 	}
 	return 1
 }
-
-func floatCompare(right, left, epsilon float32) bool {
-	a := float64(right)
-	b := float64(left)
-	e := float64(epsilon)
-	if a == b {
-		return true
-	}
-	return (math.Abs(a-b) / (math.Abs(a) + math.Abs(b))) < e
-}
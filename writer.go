@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+const (
+	WRITE_BATCH_SIZE        = 50
+	WRITE_MAX_ATTEMPTS      = 5
+	WRITE_RETRY_MIN_BACKOFF = 500 * time.Millisecond
+	WRITE_RETRY_MAX_BACKOFF = 30 * time.Second
+	DEAD_LETTER_FILE        = "dump1090reader.deadletter.jsonl"
+)
+
+// writerSettings control how finished flights are written, see addWriterFlags
+type writerSettings struct {
+	BatchSize   int
+	MaxAttempts int
+	RetryMin    time.Duration
+	RetryMax    time.Duration
+	DeadLetter  string // "" drops flights that could not be written
+}
+
+var writing = writerSettings{
+	BatchSize:   WRITE_BATCH_SIZE,
+	MaxAttempts: WRITE_MAX_ATTEMPTS,
+	RetryMin:    WRITE_RETRY_MIN_BACKOFF,
+	RetryMax:    WRITE_RETRY_MAX_BACKOFF,
+	DeadLetter:  DEAD_LETTER_FILE,
+}
+
+func addWriterFlags(fs *flag.FlagSet) {
+	fs.IntVar(&writing.BatchSize, "writeBatch", writing.BatchSize, "Most flights written to the database in one transaction")
+	fs.IntVar(&writing.MaxAttempts, "writeAttempts", writing.MaxAttempts, "While shutting down, times a batch is tried with the database unreachable before it goes to -deadLetter, until then it is retried until the database is back")
+	fs.StringVar(&writing.DeadLetter, "deadLetter", writing.DeadLetter, "JSONL file flights that could not be written are appended to, write them again with import -deadLetter")
+}
+
+// deadLetter is one line of the dead letter file, Aircraft is as it was in storage so it goes
+// through the same events, airports and simplification when it is imported again
+type deadLetter struct {
+	FailedAt int64                          `json:"failedAt"`
+	Error    string                         `json:"error"`
+	Aircraft storage.MapItem[CollectedData] `json:"aircraft"`
+}
+
+// flightWriter writes finished flights from a goroutine of its own so the scan only hands them
+// over and never waits on the database while it holds storage
+type flightWriter struct {
+	sink     storage.FlightSink
+	settings writerSettings
+	retry    backoff
+	mutex    sync.Mutex
+	pending  []storage.MapItem[CollectedData]
+	closed   bool
+	wake     chan struct{}
+	stopped  chan struct{}
+	// only read once close returns
+	written      uint64
+	deadLettered uint64
+}
+
+func newFlightWriter(sink storage.FlightSink, settings writerSettings) *flightWriter {
+	w := &flightWriter{
+		sink:     sink,
+		settings: settings,
+		retry:    backoff{min: settings.RetryMin, max: settings.RetryMax},
+		wake:     make(chan struct{}, 1),
+		stopped:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *flightWriter) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// submit queues items to be written and never blocks
+func (w *flightWriter) submit(items ...storage.MapItem[CollectedData]) {
+	if len(items) == 0 {
+		return
+	}
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		w.spill(items, errors.New("writer was already closed"))
+		return
+	}
+	w.pending = append(w.pending, items...)
+	w.mutex.Unlock()
+	w.signal()
+}
+
+// take removes the next batch, done is true once the writer is closed and nothing is left
+func (w *flightWriter) take() ([]storage.MapItem[CollectedData], bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	n := min(len(w.pending), max(1, w.settings.BatchSize))
+	batch := w.pending[:n:n]
+	w.pending = w.pending[n:]
+	return batch, w.closed && len(w.pending) == 0
+}
+
+func (w *flightWriter) run() {
+	defer close(w.stopped)
+	for {
+		batch, done := w.take()
+		if len(batch) > 0 {
+			w.write(batch)
+			continue
+		}
+		if done {
+			return
+		}
+		<-w.wake
+	}
+}
+
+// close writes everything submitted so far and returns once it is written or spilled
+func (w *flightWriter) close() {
+	w.mutex.Lock()
+	w.closed = true
+	w.mutex.Unlock()
+	w.signal()
+	<-w.stopped
+}
+
+// write takes a permanent error to be one bad flight, the batch is split and only the flights
+// that fail on their own are spilled
+func (w *flightWriter) write(batch []storage.MapItem[CollectedData]) {
+	records := make([]storage.FlightRecord, 0, len(batch))
+	for _, item := range batch {
+		records = append(records, flightRecord(item))
+	}
+	err := w.insert(records)
+	if err == nil {
+		w.written += uint64(len(records))
+		Log(fmt.Sprintf("Wrote %d aircraft to the database", len(records)), INFO)
+		return
+	}
+	// still failing on shutdown, trying each flight would only take longer
+	if len(records) == 1 || w.sink.Retryable(err) {
+		w.spill(batch, err)
+		return
+	}
+	for i := range records {
+		if err := w.insert(records[i : i+1]); err != nil {
+			w.spill(batch[i:i+1], err)
+			continue
+		}
+		w.written++
+	}
+}
+
+// insert retries errors the sink says go away on their own, with capped backoff for as long as
+// the database is down, once the writer is closing it gives up after MaxAttempts so shutdown ends
+func (w *flightWriter) insert(records []storage.FlightRecord) error {
+	defer w.retry.reset()
+	for attempt := 1; ; attempt++ {
+		err := w.sink.InsertBatch(context.Background(), records)
+		if err == nil || w.sink.Retryable(err) == false {
+			return err
+		}
+		if w.isClosed() && attempt >= max(1, w.settings.MaxAttempts) {
+			return err
+		}
+		wait := w.retry.next()
+		Log(fmt.Sprintf("Failed to write %d aircraft, attempt %d, retrying in %s: %s", len(records), attempt, wait.Round(time.Millisecond), err.Error()), WARN)
+		time.Sleep(wait)
+	}
+}
+
+func (w *flightWriter) isClosed() bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.closed
+}
+
+// spill appends items to the dead letter file, they are only lost when that fails too
+func (w *flightWriter) spill(items []storage.MapItem[CollectedData], cause error) {
+	for _, item := range items {
+		Log(fmt.Sprintf("Could not write aircraft %s to the database, moving it to %s: %s", item.Data.Icao, w.settings.DeadLetter, cause.Error()), ERROR)
+	}
+	if w.settings.DeadLetter == "" {
+		Log(fmt.Sprintf("No -deadLetter file, dropping %d aircraft", len(items)), ERROR)
+		return
+	}
+	if err := appendDeadLetters(w.settings.DeadLetter, items, cause, time.Now().UTC().UnixMilli()); err != nil {
+		Log(fmt.Sprintf("Could not write to %s, dropping %d aircraft: %s", w.settings.DeadLetter, len(items), err.Error()), ERROR)
+		return
+	}
+	w.mutex.Lock()
+	w.deadLettered += uint64(len(items))
+	w.mutex.Unlock()
+}
+
+func appendDeadLetters(path string, items []storage.MapItem[CollectedData], cause error, failedAt int64) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		b, err := json.Marshal(deadLetter{FailedAt: failedAt, Error: cause.Error(), Aircraft: item})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := f.Write(append(b, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readDeadLetters returns the aircraft in a dead letter file in the order they failed
+func readDeadLetters(path string) ([]storage.MapItem[CollectedData], error) {
+	reader, err := openMaybeGzip(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	items := make([]storage.MapItem[CollectedData], 0)
+	decoder := json.NewDecoder(reader)
+	for {
+		var line deadLetter
+		err := decoder.Decode(&line)
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return items, err
+		}
+		items = append(items, line.Aircraft)
+	}
+}
+
+// writeReady takes the aircraft ready picks out of storage and hands them to the writer
+func writeReady(itemQueue *modifyStoQueue, writer *flightWriter, ready func(item storage.MapItem[CollectedData]) bool) int {
+	items := make([]storage.MapItem[CollectedData], 0)
+	itemQueue.checkForReadyToDelete(func(item storage.MapItem[CollectedData]) {
+		if ready(item) {
+			items = append(items, item)
+			itemQueue.delete(item)
+		}
+	})
+	itemQueue.sync()
+	writer.submit(items...)
+	return len(items)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	storage "github.com/kc8/dump-1090-aggergator/storage"
+)
+
+func finishedFlight(icao string) storage.MapItem[CollectedData] {
+	return storage.MapItem[CollectedData]{Key: icao, Data: CollectedData{Icao: icao, FirstSeen: 1000, LastSeen: 2000, MsgCount: 2}}
+}
+
+func TestWriterBatchesAndRetries(t *testing.T) {
+	sink := &fakeSink{failures: 2}
+	writer := newFlightWriter(sink, testWriting(t.TempDir()))
+	writer.submit(finishedFlight("4840D6"), finishedFlight("A1B2C3"), finishedFlight("3C6586"))
+	writer.close()
+	if slices.Equal(sink.batches, []int{2, 1}) == false || writer.written != 3 || writer.deadLettered != 0 {
+		t.Fatalf("Expected batches of 2 and 1 written after 2 failures got %v, %d written %d spilled", sink.batches, writer.written, writer.deadLettered)
+	}
+}
+
+func TestWriterWaitsOutOutage(t *testing.T) {
+	settings := testWriting(t.TempDir())
+	sink := &fakeSink{failures: 4 * settings.MaxAttempts}
+	writer := newFlightWriter(sink, settings)
+	writer.submit(finishedFlight("4840D6"), finishedFlight("A1B2C3"))
+	for start := time.Now(); sink.written() < 2; time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("Expected both flights written once the database was back")
+		}
+	}
+	writer.close()
+	if writer.written != 2 || writer.deadLettered != 0 {
+		t.Fatalf("Expected nothing spilled for an outage got %d written %d spilled", writer.written, writer.deadLettered)
+	}
+}
+
+func TestWriterSpillsFlightThatKeepsFailing(t *testing.T) {
+	settings := testWriting(t.TempDir())
+	sink := &fakeSink{rejectIcao: "A1B2C3"}
+	writer := newFlightWriter(sink, settings)
+	writer.submit(finishedFlight("4840D6"), finishedFlight("A1B2C3"))
+	writer.close()
+	// the good flight in the batch is still written
+	if len(sink.records) != 1 || sink.records[0].Icao != "4840D6" || writer.deadLettered != 1 {
+		t.Fatalf("Expected 4840D6 written and A1B2C3 spilled got %v, %d spilled", sink.records, writer.deadLettered)
+	}
+	writer.submit(finishedFlight("3C6586"))
+
+	retry := &fakeSink{}
+	importer := newSbsImporter(3_600_000, func(item storage.MapItem[CollectedData]) error {
+		return insertEntry(context.Background(), retry, item)
+	})
+	count, err := importer.importDeadLetters(settings.DeadLetter)
+	if err != nil || count != 2 || len(retry.records) != 2 || retry.records[0].Icao != "A1B2C3" || retry.records[1].Icao != "3C6586" {
+		t.Fatalf("Expected A1B2C3 and 3C6586, submitted after close, to be imported again got %d %v %v", count, retry.records, err)
+	}
+}